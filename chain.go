@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/HexmosTech/dPrompts/dprompts"
+)
+
+// promptRenderer fills the placeholders of a prompt in one pass, so text it
+// substitutes, such as an output that contains "{{", is never scanned
+// again and reaches the model unchanged.
+type promptRenderer struct {
+	upstream map[string]map[string]string // results of the jobs depended on, by key
+	outputs  map[int]string               // earlier subtasks; nil keeps {{subtask_N}} as is
+	jobs     map[string]bool              // job keys referenced
+	subtasks map[int]bool                 // subtasks referenced
+}
+
+func (r *promptRenderer) render(prompt string) (string, error) {
+	if r.jobs == nil {
+		r.jobs, r.subtasks = map[string]bool{}, map[int]bool{}
+	}
+	var renderErr error
+
+	rendered := dprompts.PlaceholderPattern.ReplaceAllStringFunc(prompt, func(match string) string {
+		if renderErr != nil {
+			return match
+		}
+		if match == dprompts.LiteralBraces {
+			return "{{"
+		}
+
+		if m := dprompts.JobRefPattern.FindStringSubmatch(match); m != nil {
+			key := m[1]
+			r.jobs[key] = true
+
+			outputs, ok := r.upstream[key]
+			if !ok {
+				renderErr = fmt.Errorf("result of job %q is not available", key)
+				return match
+			}
+			value, err := lookupJobOutput(outputs, strings.TrimPrefix(m[2], "."))
+			if err != nil {
+				renderErr = fmt.Errorf("%s: %w", match, err)
+				return match
+			}
+			return value
+		}
+
+		if r.outputs == nil {
+			return match
+		}
+		m := dprompts.SubtaskRefPattern.FindStringSubmatch(match)
+		dep, _ := strconv.Atoi(m[1])
+		r.subtasks[dep] = true
+
+		output, ok := r.outputs[dep]
+		if !ok {
+			renderErr = fmt.Errorf("output of subtask_%d is not available", dep)
+			return match
+		}
+		value, err := lookupOutputPath(output, strings.TrimPrefix(m[2], "."))
+		if err != nil {
+			renderErr = fmt.Errorf("%s: %w", match, err)
+			return match
+		}
+		return value
+	})
+	if renderErr != nil {
+		return "", renderErr
+	}
+	return rendered, nil
+}
+
+// renderSubtaskPrompt fills the placeholders in the prompt with the outputs
+// of earlier subtasks and the results of upstream jobs. depends_on outputs
+// that are not referenced by a placeholder are appended to the prompt as
+// context.
+func renderSubtaskPrompt(sub DPromptsSubTask, outputs map[int]string, upstream map[string]map[string]string) (string, error) {
+	if outputs == nil {
+		outputs = map[int]string{}
+	}
+	r := promptRenderer{upstream: upstream, outputs: outputs}
+	prompt, err := r.render(sub.Prompt)
+	if err != nil {
+		return "", err
+	}
+
+	var extra strings.Builder
+	for _, dep := range sub.DependsOn {
+		if r.subtasks[dep] {
+			continue
+		}
+		output, ok := outputs[dep]
+		if !ok {
+			return "", fmt.Errorf("output of subtask_%d is not available", dep)
+		}
+		fmt.Fprintf(&extra, "\n\nOutput of subtask_%d:\n%s", dep, output)
+	}
+
+	return prompt + extra.String(), nil
+}
+
+// lookupOutputPath resolves a dotted path (e.g. "sections.0.title") inside
+// a JSON subtask output. Strings are returned as-is, other values as JSON.
+// An empty path returns the whole output.
+func lookupOutputPath(output string, path string) (string, error) {
+	if path == "" {
+		return output, nil
+	}
+
+	var current any
+	if err := json.Unmarshal([]byte(output), &current); err != nil {
+		return "", fmt.Errorf("output is not JSON: %w", err)
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]any:
+			next, ok := v[part]
+			if !ok {
+				return "", fmt.Errorf("field %q not found", part)
+			}
+			current = next
+		case []any:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return "", fmt.Errorf("invalid index %q", part)
+			}
+			current = v[idx]
+		default:
+			return "", fmt.Errorf("cannot look up %q in a %T", part, current)
+		}
+	}
+
+	if str, ok := current.(string); ok {
+		return str, nil
+	}
+
+	out, err := json.Marshal(current)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderSubtaskPrompt(t *testing.T) {
+	outputs := map[int]string{
+		0: `{"sections":[{"title":"Intro"},{"title":"Body"}],"count":2}`,
+		1: "plain text",
+		2: `tmpl {{subtask_0}} and {{job.notes}}`,
+	}
+	upstream := map[string]map[string]string{"notes": {"subtask_0": "from notes"}}
+
+	tests := []struct {
+		name    string
+		sub     DPromptsSubTask
+		want    string
+		wantErr string
+	}{
+		{"no placeholders", DPromptsSubTask{Prompt: "hello"}, "hello", ""},
+		{"whole output", DPromptsSubTask{Prompt: "Refine: {{subtask_1}}"}, "Refine: plain text", ""},
+		{"string field", DPromptsSubTask{Prompt: "Title: {{ subtask_0.sections.1.title }}"}, "Title: Body", ""},
+		{"number as JSON", DPromptsSubTask{Prompt: "n={{subtask_0.count}}"}, "n=2", ""},
+		{"object as JSON", DPromptsSubTask{Prompt: "{{subtask_0.sections.0}}"}, `{"title":"Intro"}`, ""},
+		{
+			"unreferenced depends_on appended",
+			DPromptsSubTask{Prompt: "Use {{subtask_0.count}}", DependsOn: []int{0, 1}},
+			"Use 2\n\nOutput of subtask_1:\nplain text", "",
+		},
+		{"output with placeholders kept verbatim", DPromptsSubTask{Prompt: "Fix: {{subtask_2}}"}, "Fix: tmpl {{subtask_0}} and {{job.notes}}", ""},
+		{"upstream job", DPromptsSubTask{Prompt: "{{job.notes}} / {{subtask_1}}"}, "from notes / plain text", ""},
+		{"literal braces", DPromptsSubTask{Prompt: `{{"{{"}}subtask_1}} is {{subtask_1}}`}, "{{subtask_1}} is plain text", ""},
+		{"missing output", DPromptsSubTask{Prompt: "{{subtask_4}}"}, "", "output of subtask_4 is not available"},
+		{"missing depends_on output", DPromptsSubTask{Prompt: "x", DependsOn: []int{3}}, "", "output of subtask_3 is not available"},
+		{"missing field", DPromptsSubTask{Prompt: "{{subtask_0.author}}"}, "", `field "author" not found`},
+		{"bad index", DPromptsSubTask{Prompt: "{{subtask_0.sections.5}}"}, "", `invalid index "5"`},
+		{"path into plain text", DPromptsSubTask{Prompt: "{{subtask_1.x}}"}, "", "output is not JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderSubtaskPrompt(tt.sub, outputs, upstream)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderSubtaskPrompt: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderSubtaskPrompt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
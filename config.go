@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/HexmosTech/dPrompts/dprompts"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 )
@@ -79,6 +80,30 @@ func LoadWorkerConfig(path string) (*WorkerConfig, error) {
 	if conf.Worker.ConcurrentWorkers <= 0 {
 		conf.Worker.ConcurrentWorkers = 1
 	}
+	if conf.Worker.MaxSubtaskParallelism <= 0 {
+		conf.Worker.MaxSubtaskParallelism = 1
+	}
+	for name, maxWorkers := range conf.Worker.Queues {
+		if err := dprompts.ValidateQueueAndPriority(name, 0); err != nil {
+			return nil, fmt.Errorf("worker.queues: %w", err)
+		}
+		if maxWorkers <= 0 {
+			return nil, fmt.Errorf("worker.queues: queue %q needs at least 1 worker", name)
+		}
+	}
 
 	return &conf.Worker, nil
 }
+
+func LoadServerConfig(path string) (*ServerConfig, error) {
+	var conf struct {
+		Server ServerConfig
+	}
+	if _, err := toml.DecodeFile(path, &conf); err != nil {
+		return nil, err
+	}
+	if conf.Server.Addr == "" {
+		conf.Server.Addr = "127.0.0.1:8080"
+	}
+	return &conf.Server, nil
+}
@@ -0,0 +1,59 @@
+package dprompts
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// SubtaskRefPattern matches {{subtask_N}} and {{subtask_N.field.0.name}}.
+var SubtaskRefPattern = regexp.MustCompile(`\{\{\s*subtask_(\d+)((?:\.[A-Za-z0-9_\-]+)*)\s*\}\}`)
+
+// SubtaskDependencies returns the subtasks that subtask i needs, from both
+// its depends_on list and the placeholders in its prompt. Only earlier
+// subtasks may be referenced, which keeps chains acyclic.
+func SubtaskDependencies(subTasks []SubTask, i int) ([]int, error) {
+	seen := map[int]struct{}{}
+
+	add := func(dep int) error {
+		if dep < 0 || dep >= i {
+			return fmt.Errorf("sub_task[%d] can only depend on earlier subtasks, got subtask_%d", i, dep)
+		}
+		seen[dep] = struct{}{}
+		return nil
+	}
+
+	for _, dep := range subTasks[i].DependsOn {
+		if err := add(dep); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range SubtaskRefPattern.FindAllStringSubmatch(subTasks[i].Prompt, -1) {
+		dep, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+		if err := add(dep); err != nil {
+			return nil, err
+		}
+	}
+
+	deps := make([]int, 0, len(seen))
+	for dep := range seen {
+		deps = append(deps, dep)
+	}
+	sort.Ints(deps)
+	return deps, nil
+}
+
+// validateSubtaskChain checks the dependencies of every subtask in a job.
+func validateSubtaskChain(subTasks []SubTask) error {
+	for i := range subTasks {
+		if _, err := SubtaskDependencies(subTasks, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package dprompts
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubtaskDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		subTasks []SubTask
+		i        int
+		want     []int
+		wantErr  bool
+	}{
+		{"first subtask", []SubTask{{Prompt: "x"}}, 0, []int{}, false},
+		{"placeholder", []SubTask{{Prompt: "a"}, {Prompt: "use {{subtask_0}}"}}, 1, []int{0}, false},
+		{"placeholder with path and spaces", []SubTask{{Prompt: "a"}, {Prompt: "{{ subtask_0.items.1.name }}"}}, 1, []int{0}, false},
+		{"depends_on", []SubTask{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "c", DependsOn: []int{1, 0}}}, 2, []int{0, 1}, false},
+		{"depends_on and placeholder merged", []SubTask{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "{{subtask_1}} {{subtask_1.x}}", DependsOn: []int{1, 0}}}, 2, []int{0, 1}, false},
+		{"itself", []SubTask{{Prompt: "a"}, {Prompt: "{{subtask_1}}"}}, 1, nil, true},
+		{"later subtask", []SubTask{{Prompt: "a"}, {Prompt: "b", DependsOn: []int{2}}, {Prompt: "c"}}, 1, nil, true},
+		{"negative", []SubTask{{Prompt: "a"}, {Prompt: "b", DependsOn: []int{-1}}}, 1, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SubtaskDependencies(tt.subTasks, tt.i)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SubtaskDependencies(%d) error = %v, wantErr %v", tt.i, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SubtaskDependencies(%d) = %v, want %v", tt.i, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSubtaskChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		subTasks []SubTask
+		wantErr  string
+	}{
+		{"independent", []SubTask{{Prompt: "a"}, {Prompt: "b"}}, ""},
+		{"chain", []SubTask{{Prompt: "a"}, {Prompt: "{{subtask_0}}"}, {Prompt: "{{subtask_1}}", DependsOn: []int{0}}}, ""},
+		{"forward reference", []SubTask{{Prompt: "{{subtask_1}}"}, {Prompt: "b"}}, "sub_task[0]"},
+		{"cycle", []SubTask{{Prompt: "{{subtask_1}}"}, {Prompt: "{{subtask_0}}"}}, "sub_task[0]"},
+		{"error names the subtask", []SubTask{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "c", DependsOn: []int{5}}}, "sub_task[2]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSubtaskChain(tt.subTasks)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validateSubtaskChain: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("validateSubtaskChain: %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+// Package dprompts enqueues dPrompts jobs and reads their results. It is
+// what the dpr CLI uses, so Go services can submit jobs to the same
+// workers without shelling out. The database must have been migrated with
+// `dpr db migrate up`.
+package dprompts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// BulkBatchSize is how many jobs EnqueueBulkFrom inserts per transaction.
+const BulkBatchSize = 500
+
+var (
+	// ErrNotFound is returned for jobs and results that do not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidJob wraps the errors of jobs rejected before insert.
+	ErrInvalidJob = errors.New("invalid job")
+)
+
+// Client submits jobs through an insert-only River client. It is safe for
+// concurrent use.
+type Client struct {
+	db    *pgxpool.Pool
+	river *river.Client[pgx.Tx]
+}
+
+// NewClient returns a Client that inserts jobs and reads results through
+// db. It creates its own insert-only River client, which runs no workers;
+// db stays owned by the caller and must outlive the Client.
+func NewClient(db *pgxpool.Pool) (*Client, error) {
+	riverClient, err := river.NewClient[pgx.Tx](riverpgxv5.New(db), &river.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{db: db, river: riverClient}, nil
+}
+
+// Enqueue inserts one job. Single jobs cannot use DependsOn.
+func (c *Client) Enqueue(ctx context.Context, job Job, opts EnqueueOptions) (*rivertype.JobRow, error) {
+	params, wf, err := singleJobParams(job, opts)
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.insertBatch(ctx, []river.InsertManyParams{params}, wf)
+	if err != nil {
+		return nil, err
+	}
+	return results[0].Job, nil
+}
+
+// EnqueueTx is Enqueue inserting the job in tx.
+func (c *Client) EnqueueTx(ctx context.Context, tx pgx.Tx, job Job, opts EnqueueOptions) (*rivertype.JobRow, error) {
+	params, wf, err := singleJobParams(job, opts)
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.insertBatchTx(ctx, tx, []river.InsertManyParams{params}, wf)
+	if err != nil {
+		return nil, err
+	}
+	return results[0].Job, nil
+}
+
+func singleJobParams(job Job, opts EnqueueOptions) (river.InsertManyParams, *workflow, error) {
+	if len(job.DependsOn) > 0 {
+		return river.InsertManyParams{}, nil, fmt.Errorf("%w: depends_on is only supported between jobs of a bulk", ErrInvalidJob)
+	}
+	opts.applyDefaults(&job)
+
+	wf := newWorkflow()
+	params, err := insertParams(job, wf)
+	if err != nil {
+		return river.InsertManyParams{}, nil, fmt.Errorf("%w: %w", ErrInvalidJob, err)
+	}
+	return params, wf, nil
+}
+
+// EnqueueBulk inserts the jobs in one transaction. Jobs may depend on
+// earlier jobs of the same call by key.
+func (c *Client) EnqueueBulk(ctx context.Context, jobs []Job, opts EnqueueOptions) ([]*rivertype.JobRow, error) {
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("%w: no jobs given", ErrInvalidJob)
+	}
+
+	wf := newWorkflow()
+	batch := make([]river.InsertManyParams, 0, len(jobs))
+	for i, job := range jobs {
+		opts.applyDefaults(&job)
+		params, err := insertParams(job, wf)
+		if err != nil {
+			return nil, fmt.Errorf("%w: job %d: %w", ErrInvalidJob, i, err)
+		}
+		batch = append(batch, params)
+	}
+
+	results, err := c.insertBatch(ctx, batch, wf)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*rivertype.JobRow, len(results))
+	for i, res := range results {
+		rows[i] = res.Job
+	}
+	return rows, nil
+}
+
+// EnqueueBulkFrom reads jobs as a JSON array or as newline-delimited JSON
+// and inserts them BulkBatchSize at a time, so large files are not held in
+// memory. It returns how many jobs were inserted, including those of the
+// batches committed before an error.
+func (c *Client) EnqueueBulkFrom(ctx context.Context, r io.Reader, opts EnqueueOptions) (int, error) {
+	return c.enqueueFrom(ctx, r, opts, func(batch []river.InsertManyParams, wf *workflow) error {
+		_, err := c.insertBatch(ctx, batch, wf)
+		return err
+	})
+}
+
+// EnqueueBulkFromTx is EnqueueBulkFrom inserting every job in tx, so they
+// are all enqueued, or none, when the caller commits or rolls back.
+func (c *Client) EnqueueBulkFromTx(ctx context.Context, tx pgx.Tx, r io.Reader, opts EnqueueOptions) (int, error) {
+	return c.enqueueFrom(ctx, r, opts, func(batch []river.InsertManyParams, wf *workflow) error {
+		_, err := c.insertBatchTx(ctx, tx, batch, wf)
+		return err
+	})
+}
+
+// enqueueFrom decodes jobs from r and passes them to insert BulkBatchSize
+// at a time.
+func (c *Client) enqueueFrom(ctx context.Context, r io.Reader, opts EnqueueOptions, insert func([]river.InsertManyParams, *workflow) error) (int, error) {
+	br := bufio.NewReader(r)
+	isArray, err := startsWithArray(br)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read jobs: %w", err)
+	}
+
+	decoder := json.NewDecoder(br)
+	if isArray {
+		if _, err := decoder.Token(); err != nil {
+			return 0, err
+		}
+	}
+
+	wf := newWorkflow()
+	batch := make([]river.InsertManyParams, 0, BulkBatchSize)
+	inserted, total := 0, 0
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := insert(batch, wf); err != nil {
+			return err
+		}
+		inserted += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		if isArray && !decoder.More() {
+			break
+		}
+		var job Job
+		if err := decoder.Decode(&job); err != nil {
+			if !isArray && errors.Is(err, io.EOF) {
+				break
+			}
+			return inserted, fmt.Errorf("%w: decode error at job %d: %w", ErrInvalidJob, total, err)
+		}
+
+		opts.applyDefaults(&job)
+		params, err := insertParams(job, wf)
+		if err != nil {
+			return inserted, fmt.Errorf("%w: job %d: %w", ErrInvalidJob, total, err)
+		}
+		batch = append(batch, params)
+		total++
+
+		if len(batch) == BulkBatchSize {
+			if err := flush(); err != nil {
+				return inserted, err
+			}
+		}
+	}
+
+	return inserted, flush()
+}
+
+// startsWithArray peeks at the first non-space byte of r.
+func startsWithArray(r *bufio.Reader) (bool, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			return b[0] == '[', nil
+		}
+		if _, err := r.Discard(1); err != nil {
+			return false, err
+		}
+	}
+}
+
+// GetJob returns a job's river_job row.
+func (c *Client) GetJob(ctx context.Context, jobID int64) (*rivertype.JobRow, error) {
+	job, err := c.river.JobGet(ctx, jobID)
+	if errors.Is(err, rivertype.ErrNotFound) {
+		return nil, fmt.Errorf("job %d: %w", jobID, ErrNotFound)
+	}
+	return job, err
+}
+
+// insertBatch inserts the jobs and their workflow dependencies in one
+// transaction and returns the inserted rows in batch order.
+func (c *Client) insertBatch(ctx context.Context, batch []river.InsertManyParams, wf *workflow) ([]*rivertype.JobInsertResult, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx) // safe no-op if already committed
+	}()
+
+	results, err := c.insertBatchTx(ctx, tx, batch, wf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// insertBatchTx inserts the jobs and their workflow dependencies in tx.
+func (c *Client) insertBatchTx(ctx context.Context, tx pgx.Tx, batch []river.InsertManyParams, wf *workflow) ([]*rivertype.JobInsertResult, error) {
+	results, err := c.river.InsertManyTx(ctx, tx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if err := wf.recordInserted(ctx, tx, c.river, batch, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
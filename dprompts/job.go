@@ -0,0 +1,244 @@
+package dprompts
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/riverqueue/river"
+)
+
+const maxGroupNameLength = 200
+
+// queueNamePattern matches the queue names River accepts.
+var queueNamePattern = regexp.MustCompile(`^(?:[a-z0-9])+(?:[_|\-]?[a-z0-9]+)*$`)
+
+// Job is one job to enqueue: its args plus how and when River should run
+// it. It is also the format of one entry of a bulk file.
+type Job struct {
+	SubTasks    []SubTask   `json:"sub_tasks"`
+	BasePrompt  string      `json:"base_prompt,omitempty"`
+	Model       string      `json:"model,omitempty"`
+	Options     *LLMOptions `json:"options,omitempty"`
+	Parallelism int         `json:"parallelism,omitempty"`
+	Mode        string      `json:"mode,omitempty"`
+	GroupName   string      `json:"group_name,omitempty"`
+	Queue       string      `json:"queue,omitempty"`
+	Priority    int         `json:"priority,omitempty"`
+	RunAt       string      `json:"run_at,omitempty"` // see ParseRunAt
+	Delay       string      `json:"delay,omitempty"`  // Go duration, e.g. "90m"
+	Key         string      `json:"key,omitempty"`
+	DependsOn   []string    `json:"depends_on,omitempty"`
+
+	// Metadata is stored as the River job's metadata. It defaults to the
+	// first subtask's metadata.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// EnqueueOptions apply to every job of an Enqueue or EnqueueBulk call, as
+// defaults for the jobs that do not set their own.
+type EnqueueOptions struct {
+	GroupName  string
+	ForceGroup bool // GroupName replaces the jobs' own group
+	Queue      string
+	Priority   int
+	RunAt      string
+	Delay      string
+	Metadata   map[string]interface{}
+}
+
+// applyDefaults fills the job's unset fields from the options.
+func (o EnqueueOptions) applyDefaults(job *Job) {
+	if job.GroupName == "" || (o.ForceGroup && o.GroupName != "") {
+		job.GroupName = o.GroupName
+	}
+	if job.Queue == "" {
+		job.Queue = o.Queue
+	}
+	if job.Priority == 0 {
+		job.Priority = o.Priority
+	}
+	if job.RunAt == "" && job.Delay == "" {
+		job.RunAt = o.RunAt
+		job.Delay = o.Delay
+	}
+	if job.Metadata == nil {
+		job.Metadata = o.Metadata
+	}
+}
+
+// ScheduledAt turns a run_at or delay into the time River should make the
+// job available. The zero time means now.
+func ScheduledAt(runAt, delay string, now time.Time) (time.Time, error) {
+	if runAt != "" && delay != "" {
+		return time.Time{}, fmt.Errorf("run_at and delay cannot both be set")
+	}
+	if delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid delay %q: %w", delay, err)
+		}
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("delay must not be negative")
+		}
+		return now.Add(d), nil
+	}
+	if runAt != "" {
+		return ParseRunAt(runAt, now)
+	}
+	return time.Time{}, nil
+}
+
+// ParseRunAt accepts an RFC 3339 timestamp, a local "2006-01-02 15:04", or
+// a local time of day "15:04", which means the next time the clock shows it.
+func ParseRunAt(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("15:04", s, now.Location()); err == nil {
+		next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid run_at %q: use RFC 3339, \"2006-01-02 15:04\" or \"15:04\"", s)
+}
+
+// insertParams validates a job, including its key and depends_on against
+// the jobs before it in wf, and builds its insert params.
+func insertParams(job Job, wf *workflow) (river.InsertManyParams, error) {
+	args := JobArgs{
+		BasePrompt:  job.BasePrompt,
+		SubTasks:    job.SubTasks,
+		Model:       job.Model,
+		Options:     job.Options,
+		Parallelism: job.Parallelism,
+		Mode:        job.Mode,
+		GroupName:   strings.TrimSpace(job.GroupName),
+		Key:         job.Key,
+		DependsOn:   job.DependsOn,
+	}
+
+	// Older bulk files put the group in the first subtask's metadata.
+	if args.GroupName == "" && len(job.SubTasks) > 0 {
+		if v, ok := job.SubTasks[0].Metadata["group_name"].(string); ok {
+			args.GroupName = strings.TrimSpace(v)
+		}
+	}
+
+	if err := ValidateJobArgs(args); err != nil {
+		return river.InsertManyParams{}, err
+	}
+	if err := ValidateQueueAndPriority(job.Queue, job.Priority); err != nil {
+		return river.InsertManyParams{}, err
+	}
+	if err := wf.add(args); err != nil {
+		return river.InsertManyParams{}, err
+	}
+
+	runAt, err := ScheduledAt(job.RunAt, job.Delay, time.Now())
+	if err != nil {
+		return river.InsertManyParams{}, err
+	}
+	if len(job.DependsOn) > 0 && !runAt.IsZero() {
+		return river.InsertManyParams{}, fmt.Errorf("a job with depends_on runs when its dependencies finish and cannot set run_at or delay")
+	}
+
+	opts := &river.InsertOpts{
+		Queue:       job.Queue,
+		Priority:    job.Priority,
+		ScheduledAt: runAt,
+		Pending:     len(job.DependsOn) > 0,
+	}
+	metadata := job.Metadata
+	if metadata == nil {
+		metadata = job.SubTasks[0].Metadata
+	}
+	if metadata != nil {
+		metadataBytes, err := json.Marshal(metadata)
+		if err != nil {
+			return river.InsertManyParams{}, fmt.Errorf("invalid metadata: %w", err)
+		}
+		opts.Metadata = metadataBytes
+	}
+
+	return river.InsertManyParams{
+		Args:       args,
+		InsertOpts: opts,
+	}, nil
+}
+
+// ValidateJobArgs rejects jobs the worker could never complete.
+func ValidateJobArgs(args JobArgs) error {
+	if len(args.SubTasks) == 0 {
+		return fmt.Errorf("job has no sub_tasks")
+	}
+
+	for i, st := range args.SubTasks {
+		if strings.TrimSpace(st.Prompt) == "" {
+			return fmt.Errorf("sub_task[%d] has empty prompt", i)
+		}
+	}
+
+	if err := validateSubtaskChain(args.SubTasks); err != nil {
+		return err
+	}
+
+	if args.Parallelism < 0 {
+		return fmt.Errorf("parallelism must not be negative")
+	}
+
+	if args.Mode != JobModeIndependent && args.Mode != JobModeConversation {
+		return fmt.Errorf("unknown job mode %q", args.Mode)
+	}
+
+	if args.GroupName != "" {
+		if err := ValidateGroupName(args.GroupName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateQueueAndPriority checks the queue name River would otherwise
+// reject at insert time, and River's 1 (highest) to 4 priority range.
+// Empty values mean River's defaults.
+func ValidateQueueAndPriority(queue string, priority int) error {
+	if queue != "" && !queueNamePattern.MatchString(queue) {
+		return fmt.Errorf("invalid queue name %q: use lowercase letters, digits, '-' and '_'", queue)
+	}
+	if len(queue) > 64 {
+		return fmt.Errorf("queue name must be at most 64 characters")
+	}
+	if priority < 0 || priority > 4 {
+		return fmt.Errorf("priority must be between 1 (highest) and 4, got %d", priority)
+	}
+	return nil
+}
+
+// ValidateGroupName checks a group name given at enqueue time.
+func ValidateGroupName(name string) error {
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf("group name %q has leading or trailing spaces", name)
+	}
+	if name == "" {
+		return fmt.Errorf("group name is empty")
+	}
+	if len(name) > maxGroupNameLength {
+		return fmt.Errorf("group name is longer than %d characters", maxGroupNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("group name %q contains control characters", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package dprompts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRunAt(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2025, 6, 1, 14, 30, 0, 0, loc)
+
+	tests := []struct {
+		name    string
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"RFC 3339", "2025-07-01T09:00:00Z", time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC), false},
+		{"RFC 3339 with offset", "2025-07-01T09:00:00+05:30", time.Date(2025, 7, 1, 3, 30, 0, 0, time.UTC), false},
+		{"local date and time", "2025-07-01 09:15", time.Date(2025, 7, 1, 9, 15, 0, 0, loc), false},
+		{"time later today", "18:00", time.Date(2025, 6, 1, 18, 0, 0, 0, loc), false},
+		{"time earlier today is tomorrow", "09:00", time.Date(2025, 6, 2, 9, 0, 0, 0, loc), false},
+		{"time now is tomorrow", "14:30", time.Date(2025, 6, 2, 14, 30, 0, 0, loc), false},
+		{"date only", "2025-07-01", time.Time{}, true},
+		{"garbage", "tomorrow", time.Time{}, true},
+		{"hour out of range", "25:00", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRunAt(tt.in, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRunAt(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseRunAt(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduledAt(t *testing.T) {
+	now := time.Date(2025, 6, 1, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		runAt   string
+		delay   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"neither", "", "", time.Time{}, false},
+		{"delay", "", "90m", now.Add(90 * time.Minute), false},
+		{"zero delay", "", "0s", now, false},
+		{"run_at", "2025-06-02T08:00:00Z", "", time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC), false},
+		{"both", "15:00", "1h", time.Time{}, true},
+		{"negative delay", "", "-5m", time.Time{}, true},
+		{"invalid delay", "", "soon", time.Time{}, true},
+		{"invalid run_at", "later", "", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ScheduledAt(tt.runAt, tt.delay, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ScheduledAt(%q, %q) error = %v, wantErr %v", tt.runAt, tt.delay, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ScheduledAt(%q, %q) = %s, want %s", tt.runAt, tt.delay, got, tt.want)
+			}
+		})
+	}
+}
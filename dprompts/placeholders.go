@@ -0,0 +1,21 @@
+package dprompts
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LiteralBraces stands for a literal "{{" in a prompt; rendering the prompt
+// turns it back into "{{".
+const LiteralBraces = `{{"{{"}}`
+
+// PlaceholderPattern matches LiteralBraces and both kinds of placeholders,
+// so a prompt can be rendered in one pass that never scans substituted text.
+var PlaceholderPattern = regexp.MustCompile(regexp.QuoteMeta(LiteralBraces) + `|` + JobRefPattern.String() + `|` + SubtaskRefPattern.String())
+
+// EscapePlaceholders makes text safe to paste into a prompt: the rendered
+// prompt contains the text unchanged, even where it looks like a
+// placeholder.
+func EscapePlaceholders(text string) string {
+	return strings.ReplaceAll(text, "{{", LiteralBraces)
+}
@@ -0,0 +1,137 @@
+package dprompts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// JobGroupSQL extracts a river_job's group: the group_name arg, or the
+// group_name metadata of older bulk files.
+const JobGroupSQL = `COALESCE(NULLIF(args->>'group_name', ''), metadata->>'group_name', '')`
+
+// Result is a job's row in dprompts_results.
+type Result struct {
+	ID        int                       `json:"id"`
+	JobID     int64                     `json:"job_id"`
+	GroupName string                    `json:"group_name,omitempty"`
+	Outputs   map[string]string         `json:"outputs"` // keyed subtask_N
+	Usage     map[string]SubtaskMetrics `json:"usage,omitempty"`
+	CreatedAt time.Time                 `json:"created_at"`
+}
+
+// Output is the job's output for single-subtask jobs, otherwise all
+// outputs as a JSON object.
+func (r *Result) Output() string {
+	if len(r.Outputs) == 1 {
+		for _, output := range r.Outputs {
+			return output
+		}
+	}
+	all, _ := json.Marshal(r.Outputs)
+	return string(all)
+}
+
+// Group is a dprompt_groups row with its number of results.
+type Group struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Results   int       `json:"results"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const resultColumnsSQL = `r.id, r.job_id, r.response, r.usage, r.created_at, COALESCE(g.group_name, '')`
+
+func scanResult(row pgx.Row) (*Result, error) {
+	var (
+		res      Result
+		response []byte
+		usage    []byte
+	)
+	if err := row.Scan(&res.ID, &res.JobID, &response, &usage, &res.CreatedAt, &res.GroupName); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(response, &res.Outputs); err != nil {
+		return nil, fmt.Errorf("result of job %d: %w", res.JobID, err)
+	}
+	if len(usage) > 0 {
+		if err := json.Unmarshal(usage, &res.Usage); err != nil {
+			return nil, fmt.Errorf("usage of job %d: %w", res.JobID, err)
+		}
+	}
+	return &res, nil
+}
+
+// GetResult returns the stored result of a job. It returns ErrNotFound
+// until the job has completed.
+func (c *Client) GetResult(ctx context.Context, jobID int64) (*Result, error) {
+	res, err := scanResult(c.db.QueryRow(ctx, `
+		SELECT `+resultColumnsSQL+`
+		FROM dprompts_results r
+		LEFT JOIN dprompt_groups g ON r.group_id = g.id
+		WHERE r.job_id = $1
+	`, jobID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("result of job %d: %w", jobID, ErrNotFound)
+	}
+	return res, err
+}
+
+// ListGroups returns every group with its number of results.
+func (c *Client) ListGroups(ctx context.Context) ([]Group, error) {
+	rows, err := c.db.Query(ctx, `
+		SELECT g.id, g.group_name, COUNT(r.id), g.created_at
+		FROM dprompt_groups g
+		LEFT JOIN dprompts_results r ON r.group_id = g.id
+		GROUP BY g.id, g.group_name, g.created_at
+		ORDER BY g.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []Group{}
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.Results, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// GroupResults returns a page of a group's results, newest first.
+func (c *Client) GroupResults(ctx context.Context, groupName string, limit, offset int) ([]Result, error) {
+	rows, err := c.db.Query(ctx, `
+		SELECT `+resultColumnsSQL+`
+		FROM dprompts_results r
+		JOIN dprompt_groups g ON r.group_id = g.id
+		WHERE g.group_name = $1
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT $2 OFFSET $3
+	`, groupName, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return collectResults(rows)
+}
+
+func collectResults(rows pgx.Rows) ([]Result, error) {
+	defer rows.Close()
+
+	results := []Result{}
+	for rows.Next() {
+		res, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *res)
+	}
+	return results, rows.Err()
+}
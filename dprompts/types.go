@@ -0,0 +1,96 @@
+package dprompts
+
+import "time"
+
+// SubTask is one prompt of a job. Its output is stored as subtask_N of the
+// job's result.
+type SubTask struct {
+	Prompt   string                 `json:"prompt"`
+	Schema   interface{}            `json:"schema,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Model    string                 `json:"model,omitempty"`   // overrides the job/config model
+	Options  *LLMOptions            `json:"options,omitempty"` // overrides the job/config options
+
+	// DependsOn lists earlier subtasks whose outputs this subtask needs.
+	// Outputs can also be referenced in the prompt as {{subtask_N}} or
+	// {{subtask_N.field}}, which implies the dependency.
+	DependsOn []int `json:"depends_on,omitempty"`
+}
+
+// JobArgs are the River args of a dPrompts job, as stored in river_job.
+type JobArgs struct {
+	SubTasks   []SubTask   `json:"sub_tasks"`
+	BasePrompt string      `json:"base_prompt,omitempty"`
+	Model      string      `json:"model,omitempty"`   // overrides the config model
+	Options    *LLMOptions `json:"options,omitempty"` // overrides the config options
+
+	// Parallelism is how many subtasks may call the LLM at once, capped by
+	// [worker] max_subtask_parallelism. 0 or 1 runs them sequentially.
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// Mode selects how subtasks relate to each other; see JobModeConversation.
+	Mode string `json:"mode,omitempty"`
+
+	// GroupName stores the result under this dprompt_groups entry.
+	GroupName string `json:"group_name,omitempty"`
+
+	// Key names the job within its bulk file so later jobs can list it in
+	// DependsOn. A job with DependsOn is inserted pending and released once
+	// those jobs have results, which it can use as {{job.KEY}}.
+	Key       string   `json:"key,omitempty"`
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
+func (JobArgs) Kind() string {
+	return "dprompts-worker"
+}
+
+const (
+	// JobModeIndependent (default) sends each subtask as a fresh
+	// system + user pair.
+	JobModeIndependent = ""
+	// JobModeConversation runs subtasks in order as one chat, appending
+	// every prompt and reply to the messages of the next subtask.
+	JobModeConversation = "conversation"
+)
+
+// LLMOptions are per-call sampling overrides. Nil fields fall back to the
+// next level up: subtask -> job -> [llm] config.
+type LLMOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	NumCtx      *int     `json:"num_ctx,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// LLMUsage is the token and timing information reported by the backend.
+// Field names follow Ollama's; durations are in nanoseconds.
+type LLMUsage struct {
+	PromptEvalCount int   `json:"prompt_eval_count"`
+	EvalCount       int   `json:"eval_count"`
+	TotalDuration   int64 `json:"total_duration,omitempty"`
+	LoadDuration    int64 `json:"load_duration,omitempty"`
+}
+
+func (u *LLMUsage) Add(o LLMUsage) {
+	u.PromptEvalCount += o.PromptEvalCount
+	u.EvalCount += o.EvalCount
+	u.TotalDuration += o.TotalDuration
+	u.LoadDuration += o.LoadDuration
+}
+
+// SubtaskMetrics is stored per subtask alongside each result.
+type SubtaskMetrics struct {
+	Provider string     `json:"provider"`
+	Model    string     `json:"model"`
+	Options  LLMOptions `json:"options"`
+	LLMUsage
+	Attempts   int   `json:"attempts"`     // LLM calls, including schema repairs
+	WallTimeMs int64 `json:"wall_time_ms"` // measured by the worker
+}
+
+func (m SubtaskMetrics) WallTime() time.Duration {
+	return time.Duration(m.WallTimeMs) * time.Millisecond
+}
@@ -0,0 +1,204 @@
+package dprompts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// JobFinalizedChannel is notified by the dprompts_job_finalized trigger
+// with "<job id>:<state>" whenever a job is completed, cancelled or
+// discarded, or an attempt failed and the job is retryable.
+const JobFinalizedChannel = "dprompts_job_finalized"
+
+// IsFinalState reports whether River will not run a job in this state
+// again on its own.
+func IsFinalState(state rivertype.JobState) bool {
+	switch state {
+	case rivertype.JobStateCompleted, rivertype.JobStateCancelled, rivertype.JobStateDiscarded:
+		return true
+	}
+	return false
+}
+
+// listen takes a connection out of the pool and listens on
+// JobFinalizedChannel. Callers close it, so LISTEN state does not leak
+// back into the pool.
+func (c *Client) listen(ctx context.Context) (*pgx.Conn, error) {
+	poolConn, err := c.db.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn := poolConn.Hijack()
+
+	var hasTrigger bool
+	if err := conn.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'dprompts_job_finalized')`,
+	).Scan(&hasTrigger); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+	if !hasTrigger {
+		conn.Close(context.Background())
+		return nil, errors.New("job notifications are not installed, run `dpr db migrate up`")
+	}
+
+	if _, err := conn.Exec(ctx, "LISTEN "+JobFinalizedChannel); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+	return conn, nil
+}
+
+// nextJobEvent waits for the next notification: a job reaching a final
+// state, or a failed attempt.
+func nextJobEvent(ctx context.Context, conn *pgx.Conn) (int64, rivertype.JobState, error) {
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return 0, "", err
+		}
+		idStr, state, ok := strings.Cut(n.Payload, ":")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
+			return id, rivertype.JobState(state), nil
+		}
+	}
+}
+
+// WaitForJob blocks until the job reaches a final state and returns it.
+// It listens for notifications instead of polling river_job.
+func (c *Client) WaitForJob(ctx context.Context, jobID int64) (rivertype.JobState, error) {
+	return c.WatchJob(ctx, jobID, nil)
+}
+
+// WatchJob is WaitForJob that also calls onAttemptError, if not nil, with
+// the error of each failed attempt River is going to retry, as it happens.
+// The error of the final attempt is left to the caller, in the job's row.
+func (c *Client) WatchJob(ctx context.Context, jobID int64, onAttemptError func(rivertype.AttemptError)) (rivertype.JobState, error) {
+	conn, err := c.listen(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(context.Background())
+
+	// The job may have finished before LISTEN took effect.
+	var state string
+	err = conn.QueryRow(ctx, `SELECT state::text FROM river_job WHERE id = $1`, jobID).Scan(&state)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("job %d: %w", jobID, ErrNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	if IsFinalState(rivertype.JobState(state)) {
+		return rivertype.JobState(state), nil
+	}
+
+	reported := 0
+	for {
+		id, state, err := nextJobEvent(ctx, conn)
+		if err != nil {
+			return "", err
+		}
+		if id != jobID {
+			continue
+		}
+		if IsFinalState(state) {
+			return state, nil
+		}
+		if state != rivertype.JobStateRetryable || onAttemptError == nil {
+			continue
+		}
+
+		job, err := c.GetJob(ctx, jobID)
+		if err != nil {
+			return "", err
+		}
+		for _, attemptErr := range job.Errors {
+			if attemptErr.Attempt > reported {
+				onAttemptError(attemptErr)
+				reported = attemptErr.Attempt
+			}
+		}
+	}
+}
+
+// WatchGroup calls fn with each result of the group: first those already
+// stored, then each new one as its job completes. It returns nil once no
+// job of the group is left to run, or the first error of fn or ctx.
+func (c *Client) WatchGroup(ctx context.Context, groupName string, fn func(Result) error) error {
+	conn, err := c.listen(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	// Results are picked up by what has not been delivered yet rather than
+	// by result ID: IDs are taken at insert, so a result can commit after
+	// one with a higher ID has been read.
+	delivered := []int64{} // not nil: ANY(NULL) matches nothing
+	deliverNew := func() error {
+		rows, err := conn.Query(ctx, `
+			SELECT `+resultColumnsSQL+`
+			FROM dprompts_results r
+			JOIN dprompt_groups g ON r.group_id = g.id
+			WHERE g.group_name = $1
+			  AND NOT (r.job_id = ANY($2))
+			ORDER BY r.id
+		`, groupName, delivered)
+		if err != nil {
+			return err
+		}
+		results, err := collectResults(rows)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			delivered = append(delivered, res.JobID)
+			if err := fn(res); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The first pass delivers the results stored before LISTEN took effect.
+	for {
+		if err := deliverNew(); err != nil {
+			return err
+		}
+
+		var unfinished int64
+		if err := conn.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM river_job
+			WHERE kind = $1
+			  AND state IN ('available', 'scheduled', 'pending', 'retryable', 'running')
+			  AND `+JobGroupSQL+` = $2
+		`, JobArgs{}.Kind(), groupName).Scan(&unfinished); err != nil {
+			return err
+		}
+		if unfinished == 0 {
+			// Jobs that finished since the last pass.
+			return deliverNew()
+		}
+
+		for {
+			_, state, err := nextJobEvent(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if IsFinalState(state) {
+				break
+			}
+		}
+	}
+}
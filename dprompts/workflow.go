@@ -0,0 +1,174 @@
+package dprompts
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// jobKeyPattern restricts workflow keys so they can be used in placeholders.
+var jobKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// JobRefPattern matches {{job.KEY}} and {{job.KEY.subtask_0.field}}, the
+// placeholders for upstream results in the prompts of a workflow job.
+var JobRefPattern = regexp.MustCompile(`\{\{\s*job\.([A-Za-z0-9_\-]+)((?:\.[A-Za-z0-9_\-]+)*)\s*\}\}`)
+
+// workflow tracks the job keys of one bulk file, so depends_on can name
+// jobs enqueued earlier in the same file.
+type workflow struct {
+	seen map[string]bool  // keys parsed so far
+	ids  map[string]int64 // keys inserted so far
+}
+
+func newWorkflow() *workflow {
+	return &workflow{seen: map[string]bool{}, ids: map[string]int64{}}
+}
+
+// add validates a job's key and depends_on against the jobs before it and
+// records its key. Only earlier jobs can be depended on, which keeps the
+// workflow acyclic.
+func (wf *workflow) add(args JobArgs) error {
+	for _, dep := range args.DependsOn {
+		if !wf.seen[dep] {
+			return fmt.Errorf("depends_on %q does not name a job earlier in the file", dep)
+		}
+	}
+
+	referenced := map[string]bool{}
+	for _, dep := range args.DependsOn {
+		referenced[dep] = true
+	}
+	for _, prompt := range jobPrompts(args) {
+		for _, m := range JobRefPattern.FindAllStringSubmatch(prompt, -1) {
+			if !referenced[m[1]] {
+				return fmt.Errorf("prompt references {{job.%s}} but depends_on does not list it", m[1])
+			}
+		}
+	}
+
+	if args.Key == "" {
+		return nil
+	}
+	if !jobKeyPattern.MatchString(args.Key) {
+		return fmt.Errorf("invalid job key %q: use letters, digits, '-' and '_'", args.Key)
+	}
+	if wf.seen[args.Key] {
+		return fmt.Errorf("duplicate job key %q", args.Key)
+	}
+	wf.seen[args.Key] = true
+	return nil
+}
+
+func jobPrompts(args JobArgs) []string {
+	prompts := []string{args.BasePrompt}
+	for _, sub := range args.SubTasks {
+		prompts = append(prompts, sub.Prompt)
+	}
+	return prompts
+}
+
+// recordInserted stores the dependencies of freshly inserted jobs and
+// releases those whose upstream jobs already have results, which happens
+// when a worker finished them before this batch was committed.
+func (wf *workflow) recordInserted(ctx context.Context, tx pgx.Tx, riverClient *river.Client[pgx.Tx], batch []river.InsertManyParams, results []*rivertype.JobInsertResult) error {
+	for i, res := range results {
+		args, ok := batch[i].Args.(JobArgs)
+		if !ok {
+			continue
+		}
+		if args.Key != "" {
+			wf.ids[args.Key] = res.Job.ID
+		}
+		if len(args.DependsOn) == 0 {
+			continue
+		}
+
+		upstream := make([]int64, 0, len(args.DependsOn))
+		for _, dep := range args.DependsOn {
+			id, ok := wf.ids[dep]
+			if !ok {
+				return fmt.Errorf("job key %q was not inserted", dep)
+			}
+			upstream = append(upstream, id)
+
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO dprompts_job_dependencies (job_id, depends_on_job_id, depends_on_key)
+				 VALUES ($1, $2, $3)
+				 ON CONFLICT DO NOTHING`,
+				res.Job.ID, id, dep,
+			); err != nil {
+				return err
+			}
+		}
+
+		// Locking the upstream rows waits for a worker that is completing
+		// one of them, so either it sees this job or we see its result.
+		if _, err := tx.Exec(ctx, `SELECT id FROM river_job WHERE id = ANY($1) FOR SHARE`, upstream); err != nil {
+			return err
+		}
+		if _, err := ReleaseIfReady(ctx, tx, riverClient, res.Job.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseDependents makes the pending jobs waiting on jobID available once
+// every job they depend on has stored a result, and returns the released
+// jobs. The worker runs it in the transaction that completes jobID.
+func ReleaseDependents(ctx context.Context, tx pgx.Tx, riverClient *river.Client[pgx.Tx], jobID int64) ([]int64, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT j.id
+		FROM river_job j
+		JOIN dprompts_job_dependencies d ON d.job_id = j.id
+		WHERE d.depends_on_job_id = $1
+		  AND j.state = 'pending'
+		ORDER BY j.id
+		FOR UPDATE OF j
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	dependents, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+
+	var released []int64
+	for _, id := range dependents {
+		ok, err := ReleaseIfReady(ctx, tx, riverClient, id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			released = append(released, id)
+		}
+	}
+	return released, nil
+}
+
+// ReleaseIfReady moves a pending job to available through River once all
+// of its upstream jobs have a row in dprompts_results.
+func ReleaseIfReady(ctx context.Context, tx pgx.Tx, riverClient *river.Client[pgx.Tx], jobID int64) (bool, error) {
+	var ready bool
+	err := tx.QueryRow(ctx, `
+		SELECT NOT EXISTS (
+			SELECT 1
+			FROM dprompts_job_dependencies d
+			WHERE d.job_id = $1
+			  AND NOT EXISTS (SELECT 1 FROM dprompts_results r WHERE r.job_id = d.depends_on_job_id)
+		)
+	`, jobID).Scan(&ready)
+	if err != nil || !ready {
+		return false, err
+	}
+
+	if _, err := riverClient.JobRetryTx(ctx, tx, jobID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
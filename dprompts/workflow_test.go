@@ -0,0 +1,93 @@
+package dprompts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWorkflowAdd(t *testing.T) {
+	job := func(key string, dependsOn []string, prompt string) JobArgs {
+		return JobArgs{Key: key, DependsOn: dependsOn, SubTasks: []SubTask{{Prompt: prompt}}}
+	}
+
+	tests := []struct {
+		name    string
+		jobs    []JobArgs
+		wantErr string // first error; empty when all jobs are accepted
+	}{
+		{
+			name: "chain",
+			jobs: []JobArgs{
+				job("a", nil, "first"),
+				job("b", []string{"a"}, "use {{job.a}}"),
+				job("c", []string{"a", "b"}, "use {{ job.b.subtask_0.title }}"),
+			},
+		},
+		{
+			name: "jobs without keys",
+			jobs: []JobArgs{job("", nil, "x"), job("", nil, "y")},
+		},
+		{
+			name:    "unknown key",
+			jobs:    []JobArgs{job("a", nil, "x"), job("b", []string{"missing"}, "y")},
+			wantErr: `depends_on "missing"`,
+		},
+		{
+			name:    "depends on itself",
+			jobs:    []JobArgs{job("a", []string{"a"}, "x")},
+			wantErr: `depends_on "a"`,
+		},
+		{
+			name: "cycle through a later job",
+			jobs: []JobArgs{
+				job("a", []string{"b"}, "x"),
+				job("b", []string{"a"}, "y"),
+			},
+			wantErr: `depends_on "b"`,
+		},
+		{
+			name:    "placeholder without depends_on",
+			jobs:    []JobArgs{job("a", nil, "x"), job("b", nil, "use {{job.a}}")},
+			wantErr: "{{job.a}}",
+		},
+		{
+			name: "placeholder in base prompt",
+			jobs: []JobArgs{
+				job("a", nil, "x"),
+				{Key: "b", BasePrompt: "{{job.a}}", SubTasks: []SubTask{{Prompt: "y"}}},
+			},
+			wantErr: "{{job.a}}",
+		},
+		{
+			name:    "duplicate key",
+			jobs:    []JobArgs{job("a", nil, "x"), job("a", nil, "y")},
+			wantErr: "duplicate job key",
+		},
+		{
+			name:    "invalid key",
+			jobs:    []JobArgs{job("a.b", nil, "x")},
+			wantErr: "invalid job key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := newWorkflow()
+			var err error
+			for _, args := range tt.jobs {
+				if err = wf.add(args); err != nil {
+					break
+				}
+			}
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("add: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("add succeeded, want error containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("add: %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ type ExportOptions struct {
 type ExportResult struct {
 	JobID     int64
 	Response  []byte
+	Usage     []byte
 	GroupName *string
 	CreatedAt time.Time
 }
@@ -69,6 +70,7 @@ func ExportResults(
 			SELECT
 				r.job_id,
 				r.response,
+				r.usage,
 				r.created_at,
 				g.group_name
 			FROM dprompts_results r
@@ -95,6 +97,7 @@ func ExportResults(
 			SELECT
 				r.job_id,
 				r.response,
+				r.usage,
 				r.created_at,
 				g.group_name
 			FROM dprompts_results r
@@ -137,6 +140,7 @@ func ExportResults(
 		if err := rows.Scan(
 			&r.JobID,
 			&r.Response,
+			&r.Usage,
 			&r.CreatedAt,
 			&r.GroupName,
 		); err != nil {
@@ -200,6 +204,10 @@ func writeExportFile(opts ExportOptions, r ExportResult) error {
 		},
 	}
 
+	if len(r.Usage) > 0 {
+		out["usage"] = json.RawMessage(r.Usage)
+	}
+
 	data, err := json.MarshalIndent(out, "", "  ")
 	if err != nil {
 		return err
@@ -2,25 +2,197 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/HexmosTech/dPrompts/dprompts"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var errGroupNotFound = errors.New("group not found")
+
 // DeleteGroupAndResults deletes a group by its ID and all associated results
 func DeleteGroupAndResults(ctx context.Context, db *pgxpool.Pool, groupID int) error {
+	results, groups, err := deleteGroup(ctx, db, groupID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted %d results and %d group(s) for group ID %d\n", results, groups, groupID)
+	return nil
+}
+
+// deleteGroup deletes the group's results and then the group in one
+// transaction, returning how many rows of each were deleted.
+func deleteGroup(ctx context.Context, db *pgxpool.Pool, groupID int) (results int64, groups int64, err error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
 	// Delete associated results first
-	res1, err := db.Exec(ctx, `DELETE FROM dprompts_results WHERE group_id = $1`, groupID)
+	res1, err := tx.Exec(ctx, `DELETE FROM dprompts_results WHERE group_id = $1`, groupID)
 	if err != nil {
-		return fmt.Errorf("failed to delete results: %w", err)
+		return 0, 0, fmt.Errorf("failed to delete results: %w", err)
 	}
 
 	// Delete the group itself
-	res2, err := db.Exec(ctx, `DELETE FROM dprompt_groups WHERE id = $1`, groupID)
+	res2, err := tx.Exec(ctx, `DELETE FROM dprompt_groups WHERE id = $1`, groupID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+	return res1.RowsAffected(), res2.RowsAffected(), nil
+}
+
+// resolveGroupRef looks a group up by ID (numeric ref) or by name.
+func resolveGroupRef(ctx context.Context, db *pgxpool.Pool, ref string) (int, string, error) {
+	var (
+		id   int
+		name string
+	)
+
+	err := db.QueryRow(ctx, `
+		SELECT id, group_name
+		FROM dprompt_groups
+		WHERE group_name = $1 OR id::text = $1
+		ORDER BY (group_name = $1) DESC
+		LIMIT 1
+	`, ref).Scan(&id, &name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, "", fmt.Errorf("%w: %q", errGroupNotFound, ref)
+		}
+		return 0, "", err
+	}
+
+	return id, name, nil
+}
+
+// resolveGroupID looks a group up strictly by ID, for callers that were
+// given an ID and must not match a group named like one.
+func resolveGroupID(ctx context.Context, db *pgxpool.Pool, id int) (int, string, error) {
+	var name string
+	err := db.QueryRow(ctx, `SELECT group_name FROM dprompt_groups WHERE id = $1`, id).Scan(&name)
 	if err != nil {
-		return fmt.Errorf("failed to delete group: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, "", fmt.Errorf("%w: ID %d", errGroupNotFound, id)
+		}
+		return 0, "", err
 	}
+	return id, name, nil
+}
 
-	fmt.Printf("Deleted %d results and %d group(s) for group ID %d\n", res1.RowsAffected(), res2.RowsAffected(), groupID)
+// RenameGroup renames a group. Jobs of the group that have not finished yet
+// are moved along so their results land in the renamed group.
+func RenameGroup(ctx context.Context, db *pgxpool.Pool, groupID int, oldName, newName string) error {
+	if err := dprompts.ValidateGroupName(newName); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `UPDATE dprompt_groups SET group_name = $2 WHERE id = $1`, groupID, newName); err != nil {
+		return fmt.Errorf("failed to rename group: %w", err)
+	}
+
+	res, err := tx.Exec(ctx, `
+		UPDATE river_job
+		SET args = jsonb_set(args, '{group_name}', to_jsonb($2::text))
+		WHERE kind = $3
+		  AND state NOT IN ('completed', 'cancelled', 'discarded')
+		  AND `+dprompts.JobGroupSQL+` = $1
+	`, oldName, newName, DPromptsJobArgs{}.Kind())
+	if err != nil {
+		return fmt.Errorf("failed to move pending jobs: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	fmt.Printf("Renamed group %d from %q to %q (%d unfinished job(s) moved)\n", groupID, oldName, newName, res.RowsAffected())
 	return nil
 }
+
+// viewGroupStats prints job counts per group, optionally for a single group
+// name. Queued, running, retrying and failed counts come from river_job;
+// completed is the number of stored results, since River prunes completed
+// jobs after the retention period.
+func viewGroupStats(ctx context.Context, db *pgxpool.Pool, groupName string) error {
+	rows, err := db.Query(ctx, `
+		WITH job_counts AS (
+			SELECT
+				`+dprompts.JobGroupSQL+` AS group_name,
+				COUNT(*) FILTER (WHERE state IN ('available', 'scheduled', 'pending')) AS queued,
+				COUNT(*) FILTER (WHERE state = 'running') AS running,
+				COUNT(*) FILTER (WHERE state = 'retryable') AS retrying,
+				COUNT(*) FILTER (WHERE state IN ('discarded', 'cancelled')) AS failed
+			FROM river_job
+			WHERE kind = $1
+			GROUP BY 1
+		),
+		result_counts AS (
+			SELECT g.id, g.group_name, COUNT(r.id) AS completed
+			FROM dprompt_groups g
+			LEFT JOIN dprompts_results r ON r.group_id = g.id
+			GROUP BY g.id, g.group_name
+		)
+		SELECT
+			rc.id,
+			COALESCE(rc.group_name, jc.group_name) AS name,
+			COALESCE(jc.queued, 0),
+			COALESCE(jc.running, 0),
+			COALESCE(jc.retrying, 0),
+			COALESCE(jc.failed, 0),
+			COALESCE(rc.completed, 0)
+		FROM result_counts rc
+		FULL OUTER JOIN job_counts jc ON jc.group_name = rc.group_name
+		WHERE COALESCE(rc.group_name, jc.group_name) <> ''
+		  AND ($2 = '' OR COALESCE(rc.group_name, jc.group_name) = $2)
+		ORDER BY name
+	`, DPromptsJobArgs{}.Kind(), groupName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := false
+	for rows.Next() {
+		found = true
+
+		var (
+			id                                           *int
+			name                                         string
+			queued, running, retrying, failed, completed int64
+		)
+		if err := rows.Scan(&id, &name, &queued, &running, &retrying, &failed, &completed); err != nil {
+			return err
+		}
+
+		idStr := "-"
+		if id != nil {
+			idStr = fmt.Sprint(*id)
+		}
+
+		fmt.Printf(
+			"ID: %s | Name: %s | Queued: %d | Running: %d | Retrying: %d | Failed: %d | Completed: %d\n",
+			idStr, name, queued, running, retrying, failed, completed,
+		)
+	}
+
+	if !found {
+		fmt.Println("No groups found")
+	}
+
+	return rows.Err()
+}
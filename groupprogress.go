@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/HexmosTech/dPrompts/dprompts"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+type GroupProgress struct {
+	GroupName  string
+	Completed  int64 // results stored for the group
+	Failed     int64 // discarded or cancelled jobs
+	Remaining  int64 // jobs not yet finished, including retries
+	Running    int64
+	RecentDone int64         // results stored within Window
+	Window     time.Duration // throughput window
+}
+
+func (p *GroupProgress) Total() int64 {
+	return p.Completed + p.Failed + p.Remaining
+}
+
+func (p *GroupProgress) Finished() bool {
+	return p.Total() > 0 && p.Remaining == 0
+}
+
+// Throughput is the number of results stored per minute over the window.
+func (p *GroupProgress) Throughput() float64 {
+	if p.Window <= 0 {
+		return 0
+	}
+	return float64(p.RecentDone) / p.Window.Minutes()
+}
+
+// ETA estimates the time left at the recent throughput; ok is false when
+// nothing completed within the window.
+func (p *GroupProgress) ETA() (time.Duration, bool) {
+	tp := p.Throughput()
+	if tp <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(p.Remaining) / tp * float64(time.Minute)), true
+}
+
+// GetGroupProgress counts the group's jobs in river_job and its results in
+// dprompts_results.
+func GetGroupProgress(ctx context.Context, db *pgxpool.Pool, groupName string, window time.Duration) (*GroupProgress, error) {
+	p := &GroupProgress{GroupName: groupName, Window: window}
+
+	err := db.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE state IN ('discarded', 'cancelled')),
+			COUNT(*) FILTER (WHERE state IN ('available', 'scheduled', 'pending', 'retryable', 'running')),
+			COUNT(*) FILTER (WHERE state = 'running')
+		FROM river_job
+		WHERE kind = $1
+		  AND `+dprompts.JobGroupSQL+` = $2
+	`, DPromptsJobArgs{}.Kind(), groupName).Scan(&p.Failed, &p.Remaining, &p.Running)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.QueryRow(ctx, `
+		SELECT
+			COUNT(r.id),
+			COUNT(r.id) FILTER (WHERE r.created_at >= NOW() - make_interval(secs => $2))
+		FROM dprompts_results r
+		JOIN dprompt_groups g ON r.group_id = g.id
+		WHERE g.group_name = $1
+	`, groupName, window.Seconds()).Scan(&p.Completed, &p.RecentDone)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func printGroupProgress(p *GroupProgress) {
+	total := p.Total()
+	pct := 0.0
+	if total > 0 {
+		pct = float64(p.Completed+p.Failed) / float64(total) * 100
+	}
+
+	eta := "unknown"
+	if p.Finished() {
+		eta = "done"
+	} else if d, ok := p.ETA(); ok {
+		eta = fmt.Sprintf("%s (at %s)", humanizeDuration(d), time.Now().Add(d).Format("15:04"))
+	}
+
+	fmt.Printf(
+		"[%s] Group: %s | %.1f%% | Completed: %d | Failed: %d | Remaining: %d (running: %d) | Throughput: %.1f jobs/min | ETA: %s\n",
+		time.Now().Format("15:04:05"),
+		p.GroupName,
+		pct,
+		p.Completed,
+		p.Failed,
+		p.Remaining,
+		p.Running,
+		p.Throughput(),
+		eta,
+	)
+}
+
+// ViewGroupProgress prints the group's progress once.
+func ViewGroupProgress(ctx context.Context, db *pgxpool.Pool, groupName string, window time.Duration) (*GroupProgress, error) {
+	p, err := GetGroupProgress(ctx, db, groupName, window)
+	if err != nil {
+		return nil, err
+	}
+	if p.Total() == 0 {
+		return nil, fmt.Errorf("no jobs or results found for group %q", groupName)
+	}
+
+	printGroupProgress(p)
+	return p, nil
+}
+
+// WatchGroupProgress prints the group's progress every interval until all
+// its jobs are finished or ctx is cancelled, and returns the final counts.
+func WatchGroupProgress(ctx context.Context, db *pgxpool.Pool, groupName string, interval, window time.Duration) (*GroupProgress, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p, err := ViewGroupProgress(ctx, db, groupName, window)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.Finished() {
+			event := log.Info()
+			if p.Failed > 0 {
+				event = log.Warn()
+			}
+			event.
+				Str("group_name", groupName).
+				Int64("completed", p.Completed).
+				Int64("failed", p.Failed).
+				Msg("Group finished")
+			return p, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return p, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// ShowJob prints everything known about a job: its decoded args and
+// metadata, every failed attempt, and the stored result or, for an
+// unfinished job, the subtask outputs saved so far.
+func ShowJob(ctx context.Context, db *pgxpool.Pool, riverClient *river.Client[pgx.Tx], jobID int64, showTrace bool) error {
+	job, err := riverClient.JobGet(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, rivertype.ErrNotFound) {
+			return fmt.Errorf("job %d not found", jobID)
+		}
+		return err
+	}
+
+	fmt.Printf("Job %d | Kind: %s | State: %s | Queue: %s | Priority: %d | Attempts: %d/%d\n",
+		job.ID, job.Kind, job.State, job.Queue, job.Priority, job.Attempt, job.MaxAttempts)
+	fmt.Printf("CreatedAt: %s | ScheduledAt: %s | AttemptedAt: %s | FinalizedAt: %s\n",
+		job.CreatedAt.Format(time.RFC3339),
+		job.ScheduledAt.Format(time.RFC3339),
+		formatOptionalTime(job.AttemptedAt),
+		formatOptionalTime(job.FinalizedAt))
+
+	fmt.Println("\nMetadata:")
+	fmt.Println(indentJSON(job.Metadata))
+
+	fmt.Println("\nArgs:")
+	if job.Kind == (DPromptsJobArgs{}).Kind() {
+		var args DPromptsJobArgs
+		if err := json.Unmarshal(job.EncodedArgs, &args); err != nil {
+			fmt.Printf("  (failed to decode args: %v)\n", err)
+			fmt.Println(indentJSON(job.EncodedArgs))
+		} else {
+			printJobArgs(args)
+		}
+	} else {
+		fmt.Println(indentJSON(job.EncodedArgs))
+	}
+
+	fmt.Printf("\nErrors (%d):\n", len(job.Errors))
+	for _, e := range job.Errors {
+		fmt.Printf("  Attempt %d | At: %s | Error: %s\n", e.Attempt, e.At.Format(time.RFC3339), e.Error)
+		if showTrace && e.Trace != "" {
+			fmt.Println(indentText(e.Trace, "    "))
+		}
+	}
+
+	found, err := printStoredResult(ctx, db, jobID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if err := printPartialSubtaskResults(ctx, db, jobID); err != nil {
+			return err
+		}
+	}
+
+	return printSchemaAttempts(ctx, db, jobID)
+}
+
+func printJobArgs(args DPromptsJobArgs) {
+	fmt.Printf("  Group: %s\n", displayGroup(args.GroupName))
+	if args.Key != "" {
+		fmt.Printf("  Key: %s\n", args.Key)
+	}
+	if len(args.DependsOn) > 0 {
+		fmt.Printf("  DependsOn: %s\n", strings.Join(args.DependsOn, ", "))
+	}
+	if args.Model != "" {
+		fmt.Printf("  Model: %s\n", args.Model)
+	}
+	if args.Mode != "" {
+		fmt.Printf("  Mode: %s\n", args.Mode)
+	}
+	if args.Parallelism > 0 {
+		fmt.Printf("  Parallelism: %d\n", args.Parallelism)
+	}
+	if args.Options != nil {
+		opts, _ := json.Marshal(args.Options)
+		fmt.Printf("  Options: %s\n", opts)
+	}
+	if args.BasePrompt != "" {
+		fmt.Printf("  Base prompt:\n%s\n", indentText(args.BasePrompt, "    "))
+	}
+
+	for i, sub := range args.SubTasks {
+		fmt.Printf("  subtask_%d:\n", i)
+		fmt.Printf("    Prompt:\n%s\n", indentText(sub.Prompt, "      "))
+		if sub.Model != "" {
+			fmt.Printf("    Model: %s\n", sub.Model)
+		}
+		if sub.Options != nil {
+			opts, _ := json.Marshal(sub.Options)
+			fmt.Printf("    Options: %s\n", opts)
+		}
+		if len(sub.DependsOn) > 0 {
+			fmt.Printf("    DependsOn: %v\n", sub.DependsOn)
+		}
+		if sub.Schema != nil {
+			schema, _ := json.MarshalIndent(sub.Schema, "", "  ")
+			fmt.Printf("    Schema:\n%s\n", indentText(string(schema), "      "))
+		}
+		if len(sub.Metadata) > 0 {
+			meta, _ := json.Marshal(sub.Metadata)
+			fmt.Printf("    Metadata: %s\n", meta)
+		}
+	}
+}
+
+// printStoredResult prints the job's row in dprompts_results, if any.
+func printStoredResult(ctx context.Context, db *pgxpool.Pool, jobID int64) (bool, error) {
+	var (
+		response  []byte
+		usage     []byte
+		createdAt time.Time
+		groupName *string
+	)
+	err := db.QueryRow(ctx, `
+		SELECT r.response, r.usage, r.created_at, g.group_name
+		FROM dprompts_results r
+		LEFT JOIN dprompt_groups g ON r.group_id = g.id
+		WHERE r.job_id = $1
+	`, jobID).Scan(&response, &usage, &createdAt, &groupName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	gn := "NULL"
+	if groupName != nil {
+		gn = *groupName
+	}
+	fmt.Printf("\nResult | Group: %s | CreatedAt: %s\n", gn, createdAt.Format(time.RFC3339))
+
+	var outputs map[string]string
+	if err := json.Unmarshal(response, &outputs); err != nil {
+		fmt.Println(indentJSON(response))
+	} else {
+		for _, key := range sortedSubtaskKeys(outputs) {
+			printSubtaskOutput(key, outputs[key])
+		}
+	}
+	fmt.Print(formatUsage(usage))
+
+	return true, nil
+}
+
+// printPartialSubtaskResults prints the subtask outputs an unfinished job
+// has saved so far.
+func printPartialSubtaskResults(ctx context.Context, db *pgxpool.Pool, jobID int64) error {
+	rows, err := db.Query(ctx, `
+		SELECT subtask_index, response, usage
+		FROM dprompts_subtask_results
+		WHERE job_id = $1
+		ORDER BY subtask_index
+	`, jobID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	outputs := map[string]string{}
+	metrics := map[string]SubtaskMetrics{}
+	for rows.Next() {
+		var (
+			index    int
+			response string
+			usage    []byte
+		)
+		if err := rows.Scan(&index, &response, &usage); err != nil {
+			return err
+		}
+		key := fmt.Sprintf("subtask_%d", index)
+		outputs[key] = response
+
+		var m SubtaskMetrics
+		if len(usage) > 0 && json.Unmarshal(usage, &m) == nil {
+			metrics[key] = m
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(outputs) == 0 {
+		fmt.Println("\nResult: none stored")
+		return nil
+	}
+
+	fmt.Printf("\nPartial results (%d subtask(s) finished):\n", len(outputs))
+	for _, key := range sortedSubtaskKeys(outputs) {
+		printSubtaskOutput(key, outputs[key])
+	}
+	if usage, err := json.Marshal(metrics); err == nil {
+		fmt.Print(formatUsage(usage))
+	}
+	return nil
+}
+
+func printSchemaAttempts(ctx context.Context, db *pgxpool.Pool, jobID int64) error {
+	rows, err := db.Query(ctx, `
+		SELECT subtask_index, attempt, COALESCE(output, ''), COALESCE(validation_error, ''), created_at
+		FROM dprompts_schema_attempts
+		WHERE job_id = $1
+		ORDER BY subtask_index, created_at, attempt
+	`, jobID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	header := false
+	for rows.Next() {
+		var (
+			subtask, attempt        int
+			output, validationError string
+			createdAt               time.Time
+		)
+		if err := rows.Scan(&subtask, &attempt, &output, &validationError, &createdAt); err != nil {
+			return err
+		}
+		if !header {
+			fmt.Println("\nSchema attempts:")
+			header = true
+		}
+
+		status := "valid"
+		if validationError != "" {
+			status = "invalid: " + validationError
+		}
+		fmt.Printf("  subtask_%d | Attempt %d | At: %s | %s\n", subtask, attempt, createdAt.Format(time.RFC3339), status)
+		if validationError != "" && output != "" {
+			fmt.Println(indentText(output, "    "))
+		}
+	}
+	return rows.Err()
+}
+
+func printSubtaskOutput(key, output string) {
+	var v any
+	if err := json.Unmarshal([]byte(output), &v); err == nil {
+		pretty, _ := json.MarshalIndent(normalizeJSON(v), "", "  ")
+		output = string(pretty)
+	}
+	fmt.Printf("  %s:\n%s\n", key, indentText(output, "    "))
+}
+
+func indentJSON(raw []byte) string {
+	if len(raw) == 0 {
+		return "  {}"
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return indentText(string(raw), "  ")
+	}
+	pretty, _ := json.MarshalIndent(v, "", "  ")
+	return indentText(string(pretty), "  ")
+}
+
+func indentText(s, prefix string) string {
+	return prefix + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+prefix)
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -2,11 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/HexmosTech/dPrompts/dprompts"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/riverqueue/river/rivertype"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -35,6 +42,9 @@ func main() {
 
 	// ---- Client subcommand ----
 	var argsJSON, metadataJSON, bulkFile string
+	var clientOpts dprompts.EnqueueOptions
+	var wait bool
+	var waitTimeout time.Duration
 	clientCmd := &cobra.Command{
 		Use:   "client",
 		Short: "Enqueue a job",
@@ -45,21 +55,52 @@ func main() {
 				log.Fatal().Err(err).Msg("Failed to connect to database")
 			}
 			defer dbPool.Close()
-			driver := riverpgxv5.New(dbPool)
-			RunClient(ctx, driver, argsJSON, metadataJSON, bulkFile, dbPool)
+			client, err := dprompts.NewClient(dbPool)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to create client")
+			}
+			jobID := RunClient(ctx, client, argsJSON, metadataJSON, bulkFile, clientOpts)
+			if !wait {
+				return
+			}
+			if err := WaitForResult(ctx, client, jobID, waitTimeout); err != nil {
+				log.Fatal().Err(err).Msg("Job did not complete")
+			}
 		},
 	}
 	clientCmd.Flags().StringVar(&argsJSON, "args", "", "Job args as JSON")
 	clientCmd.Flags().StringVar(&metadataJSON, "metadata", "", "Job metadata as JSON")
 	clientCmd.Flags().StringVar(&bulkFile, "bulk-from-file", "", "Bulk insert jobs from JSON file")
+	clientCmd.Flags().StringVar(&clientOpts.GroupName, "group", "", "Group name for the job (default group for bulk jobs without group_name)")
+	clientCmd.Flags().StringVar(&clientOpts.Queue, "queue", "", "Queue to enqueue into (default: River's default queue; default for bulk jobs without queue)")
+	clientCmd.Flags().IntVar(&clientOpts.Priority, "priority", 0, "Job priority from 1 (highest) to 4 (default: 1; default for bulk jobs without priority)")
+	clientCmd.Flags().StringVar(&clientOpts.RunAt, "run-at", "", "Do not run before this time: RFC 3339, \"2006-01-02 15:04\" or \"15:04\" (next occurrence)")
+	clientCmd.Flags().StringVar(&clientOpts.Delay, "delay", "", "Do not run before this duration has passed, e.g. 30m or 8h")
+	clientCmd.Flags().BoolVar(&wait, "wait", false, "Wait for the job to finish and print its result to stdout")
+	clientCmd.Flags().DurationVar(&waitTimeout, "timeout", 0, "Give up waiting after this duration, e.g. 5m (default: wait forever)")
+	clientCmd.MarkFlagsMutuallyExclusive("run-at", "delay")
+	clientCmd.MarkFlagsMutuallyExclusive("wait", "bulk-from-file")
 
 	// ---- Worker subcommand ----
 	workerCmd := &cobra.Command{
 		Use:   "worker",
 		Short: "Run the worker",
 		Run: func(cmd *cobra.Command, args []string) {
+			llmConfig, err := LoadLLMConfig(configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to load LLM config")
+			}
+
+			provider, err := NewProvider(llmConfig)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to create LLM provider")
+			}
+
+			if err := provider.HealthCheck(context.Background()); err != nil {
+				if provider.Name() != "ollama" {
+					log.Fatal().Err(err).Str("provider", provider.Name()).Msg("LLM provider is not reachable")
+				}
 
-			if !isOllamaRunning() {
 				log.Warn().Msg("Ollama server is not running")
 
 				if !askForConfirmation("Ollama is not running. Do you want me to start it for you?") {
@@ -72,7 +113,7 @@ func main() {
 				}
 
 				log.Info().Msg("Waiting for Ollama to become ready...")
-				if err := waitForOllama(15 * time.Second); err != nil {
+				if err := waitForOllama(context.Background(), provider, 15*time.Second); err != nil {
 					log.Fatal().Err(err).Msg("Ollama did not become ready")
 				}
 
@@ -89,7 +130,7 @@ func main() {
 			defer dbPool.Close()
 
 			driver := riverpgxv5.New(dbPool)
-			RunWorker(ctx, driver, cancel, dbPool)
+			RunWorker(ctx, driver, cancel, dbPool, configPath, provider, llmConfig)
 		},
 	}
 
@@ -122,41 +163,204 @@ func main() {
 			}
 		},
 	}
-	// viewCmd.Flags().BoolVar(&totalGroups, "total-groups", false, "Display total number of groups")
-	// viewCmd.Flags().IntVar(&groupID, "group", 0, "Display results for a specific group ID")
+	viewCmd.Flags().BoolVar(&totalGroups, "total-groups", false, "Display all groups (same as: dpr group list)")
+	viewCmd.Flags().IntVar(&groupID, "group", 0, "Display results for a specific group ID (same as: dpr group show)")
 	viewCmd.Flags().IntVarP(&n, "number", "n", 10, "Number of results to display")
 
-	// ---- Delete-group subcommand ----
-	var deleteGroupID int
-	// ---- Delete-group subcommand ----
+	// ---- Group subcommands ----
+	groupCmd := &cobra.Command{
+		Use:   "group",
+		Short: "Group operations",
+	}
 
-	deleteGroupCmd := &cobra.Command{
-		Use:   "delete-group",
-		Short: "Delete a group and its results",
+	groupListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all groups with their result counts",
 		Run: func(cmd *cobra.Command, args []string) {
-			if deleteGroupID == 0 {
-				log.Fatal().Msg("Please provide --group-id")
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
 			}
+			defer dbPool.Close()
+			if err := viewTotalGroups(ctx, dbPool); err != nil {
+				log.Fatal().Err(err).Msg("Failed to list groups")
+			}
+		},
+	}
 
-			if !askForConfirmation(fmt.Sprintf("Are you sure you want to delete group %d and all its results?", deleteGroupID)) {
-				log.Info().Msg("Deletion cancelled by user")
-				return
+	groupShowCmd := &cobra.Command{
+		Use:   "show <group>",
+		Short: "Show the results of a group (by name or ID)",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
 			}
+			defer dbPool.Close()
 
+			id, _, err := resolveGroupRef(ctx, dbPool, args[0])
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to find group")
+			}
+			if err := viewResultsByGroup(ctx, dbPool, id); err != nil {
+				log.Fatal().Err(err).Msg("Failed to get results by group")
+			}
+		},
+	}
+
+	groupRenameCmd := &cobra.Command{
+		Use:   "rename <group> <new-name>",
+		Short: "Rename a group (by name or ID)",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
 			ctx := context.Background()
 			dbPool, err := NewDBPool(ctx, configPath)
 			if err != nil {
 				log.Fatal().Err(err).Msg("Failed to connect to database")
 			}
 			defer dbPool.Close()
-			if err := DeleteGroupAndResults(ctx, dbPool, deleteGroupID); err != nil {
-				log.Fatal().Err(err).Msg("Failed to delete group and results")
+
+			id, name, err := resolveGroupRef(ctx, dbPool, args[0])
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to find group")
+			}
+			if err := RenameGroup(ctx, dbPool, id, name, args[1]); err != nil {
+				log.Fatal().Err(err).Msg("Failed to rename group")
+			}
+		},
+	}
+
+	groupStatsCmd := &cobra.Command{
+		Use:   "stats [group-name]",
+		Short: "Show queued, running, failed and completed job counts per group",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+
+			var name string
+			if len(args) == 1 {
+				name = args[0]
+			}
+			if err := viewGroupStats(ctx, dbPool, name); err != nil {
+				log.Fatal().Err(err).Msg("Failed to get group stats")
+			}
+		},
+	}
+
+	// confirmAndDeleteGroup is shared by "group delete" and the deprecated
+	// "delete-group", which differ in how the group is looked up.
+	confirmAndDeleteGroup := func(resolve func(ctx context.Context, db *pgxpool.Pool) (int, string, error)) {
+		ctx := context.Background()
+		dbPool, err := NewDBPool(ctx, configPath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to connect to database")
+		}
+		defer dbPool.Close()
+
+		id, name, err := resolve(ctx, dbPool)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to find group")
+		}
+
+		if !askForConfirmation(fmt.Sprintf("Are you sure you want to delete group %q (ID %d) and all its results?", name, id)) {
+			log.Info().Msg("Deletion cancelled by user")
+			return
+		}
+
+		if err := DeleteGroupAndResults(ctx, dbPool, id); err != nil {
+			log.Fatal().Err(err).Msg("Failed to delete group and results")
+		}
+		log.Info().Int("group_id", id).Str("group_name", name).Msg("Deleted group and associated results")
+	}
+
+	groupDeleteCmd := &cobra.Command{
+		Use:   "delete <group>",
+		Short: "Delete a group (by name or ID) and its results",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			confirmAndDeleteGroup(func(ctx context.Context, db *pgxpool.Pool) (int, string, error) {
+				return resolveGroupRef(ctx, db, args[0])
+			})
+		},
+	}
+
+	// ---- Delete-group subcommand (deprecated) ----
+	var deleteGroupID int
+	deleteGroupCmd := &cobra.Command{
+		Use:        "delete-group",
+		Short:      "Delete a group and its results",
+		Hidden:     true,
+		Deprecated: "use \"dpr group delete <group>\" instead",
+		Args:       cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if deleteGroupID == 0 {
+				log.Fatal().Msg("Please provide --group-id")
 			}
-			log.Info().Int("group_id", deleteGroupID).Msg("Deleted group and associated results")
+			confirmAndDeleteGroup(func(ctx context.Context, db *pgxpool.Pool) (int, string, error) {
+				return resolveGroupID(ctx, db, deleteGroupID)
+			})
 		},
 	}
 	deleteGroupCmd.Flags().IntVar(&deleteGroupID, "group-id", 0, "Group ID to delete")
 
+	var (
+		progressWatch    bool
+		progressInterval time.Duration
+		progressWindow   time.Duration
+	)
+
+	groupProgressCmd := &cobra.Command{
+		Use:   "progress <group-name>",
+		Short: "Show completed/failed/remaining jobs, throughput and ETA for a group",
+		Long: "Show completed/failed/remaining jobs, throughput and ETA for a group.\n" +
+			"With --watch, refreshes until every job of the group has finished, then\n" +
+			"exits with code 0, or 2 if any job of the group failed.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+
+			if !progressWatch {
+				if _, err := ViewGroupProgress(ctx, dbPool, args[0], progressWindow); err != nil {
+					log.Fatal().Err(err).Msg("Failed to get group progress")
+				}
+				return
+			}
+
+			p, err := WatchGroupProgress(ctx, dbPool, args[0], progressInterval, progressWindow)
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					log.Info().Msg("Stopped watching group")
+					return
+				}
+				log.Fatal().Err(err).Msg("Failed to watch group progress")
+			}
+			if p.Failed > 0 {
+				dbPool.Close()
+				os.Exit(2)
+			}
+		},
+	}
+	groupProgressCmd.Flags().BoolVar(&progressWatch, "watch", false, "Keep refreshing until the group finishes")
+	groupProgressCmd.Flags().DurationVar(&progressInterval, "interval", 10*time.Second, "Refresh interval for --watch")
+	groupProgressCmd.Flags().DurationVar(&progressWindow, "window", 10*time.Minute, "Time window used to compute throughput")
+
+	groupCmd.AddCommand(groupListCmd, groupShowCmd, groupRenameCmd, groupStatsCmd, groupProgressCmd, groupDeleteCmd)
+
 	// ---- Queue subcommands ----
 	var queueN int
 
@@ -292,7 +496,351 @@ func main() {
 	}
 	queueCompletedLastCmd.Flags().IntVarP(&queueN, "number", "n", 10, "Number of jobs to display")
 	queueCompletedCmd.AddCommand(queueCompletedCountCmd, queueCompletedFirstCmd, queueCompletedLastCmd)
-	queueCmd.AddCommand(queueViewCmd, queueCountCmd, queueClearCmd, queueFailedCmd, queueCompletedCmd)
+
+	var showTrace bool
+	queueShowCmd := &cobra.Command{
+		Use:   "show <job-id>",
+		Short: "Show a job's args, metadata, errors and results",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			jobID, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Invalid job ID")
+			}
+
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+
+			riverClient, err := newRiverClient(riverpgxv5.New(dbPool))
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to create River client")
+			}
+			if err := ShowJob(ctx, dbPool, riverClient, jobID, showTrace); err != nil {
+				log.Fatal().Err(err).Msg("Failed to show job")
+			}
+		},
+	}
+	queueShowCmd.Flags().BoolVar(&showTrace, "trace", false, "Also print the stack trace of each error")
+
+	// retry, cancel and discard take a job ID or, without one, filters
+	// selecting the jobs to act on.
+	newJobActionCmd := func(action JobAction, short string) *cobra.Command {
+		var (
+			filter    JobFilter
+			metaFlags []string
+			yes       bool
+		)
+
+		cmd := &cobra.Command{
+			Use:   string(action) + " [job-id]",
+			Short: short,
+			Args:  cobra.MaximumNArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				if err := filter.ParseMetadataFilters(metaFlags); err != nil {
+					log.Fatal().Err(err).Msg("Invalid --meta filter")
+				}
+				if len(args) == 0 && filter.IsEmpty() {
+					log.Fatal().Msgf("Give a job ID or at least one of --state, --group, --kind, --meta to %s jobs in bulk", action)
+				}
+				if len(args) == 1 && !filter.IsEmpty() {
+					log.Fatal().Msg("Filters cannot be combined with a job ID")
+				}
+
+				ctx := context.Background()
+				dbPool, err := NewDBPool(ctx, configPath)
+				if err != nil {
+					log.Fatal().Err(err).Msg("Failed to connect to database")
+				}
+				defer dbPool.Close()
+
+				riverClient, err := newRiverClient(riverpgxv5.New(dbPool))
+				if err != nil {
+					log.Fatal().Err(err).Msg("Failed to create River client")
+				}
+
+				if len(args) == 1 {
+					jobID, err := strconv.ParseInt(args[0], 10, 64)
+					if err != nil {
+						log.Fatal().Err(err).Msg("Invalid job ID")
+					}
+					job, err := ApplyJobAction(ctx, riverClient, dbPool, action, jobID)
+					if err != nil {
+						log.Fatal().Err(err).Int64("job_id", jobID).Msgf("Failed to %s job", action)
+					}
+					fmt.Printf("Job %d | State: %s | Attempts: %d/%d\n", job.ID, job.State, job.Attempt, job.MaxAttempts)
+					return
+				}
+
+				jobs, err := FindJobs(ctx, riverClient, filter, action.defaultStates())
+				if err != nil {
+					log.Fatal().Err(err).Msg("Failed to list matching jobs")
+				}
+				if len(jobs) == 0 {
+					fmt.Println("No matching jobs")
+					return
+				}
+				if !yes && !askForConfirmation(fmt.Sprintf("Are you sure you want to %s %d job(s)?", action, len(jobs))) {
+					log.Info().Msgf("Bulk %s aborted by user", action)
+					return
+				}
+
+				ok := ApplyJobActionMany(ctx, riverClient, dbPool, action, jobs)
+				fmt.Printf("Updated %d of %d job(s)\n", ok, len(jobs))
+				if ok < len(jobs) {
+					dbPool.Close()
+					os.Exit(1)
+				}
+			},
+		}
+		cmd.Flags().StringSliceVar(&filter.States, "state", nil, "Only jobs in these states (comma separated)")
+		cmd.Flags().StringVar(&filter.Group, "group", "", "Only jobs in this group")
+		cmd.Flags().StringVar(&filter.Kind, "kind", "", "Only jobs of this kind")
+		cmd.Flags().StringArrayVar(&metaFlags, "meta", nil, "Only jobs whose metadata has key, or key=value (repeatable)")
+		cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt for bulk actions")
+		return cmd
+	}
+
+	queueRetryCmd := newJobActionCmd(JobActionRetry, "Retry a job, or matching failed jobs, as soon as possible")
+	queueCancelCmd := newJobActionCmd(JobActionCancel, "Cancel a job, or matching unfinished jobs")
+	queueDiscardCmd := newJobActionCmd(JobActionDiscard, "Discard a job, or matching waiting jobs, without running it again")
+
+	// discarded, cancelled, retryable, running and pending jobs each get
+	// view and count; discarded, cancelled and retryable jobs can be retried
+	// in bulk. Pending jobs are released by the jobs they depend on.
+	newStateCmd := func(state rivertype.JobState) *cobra.Command {
+		stateCmd := &cobra.Command{
+			Use:   string(state),
+			Short: fmt.Sprintf("Operations on %s jobs", state),
+		}
+
+		viewCmd := &cobra.Command{
+			Use:   "view",
+			Short: fmt.Sprintf("View %s jobs", state),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				dbPool, err := NewDBPool(ctx, configPath)
+				if err != nil {
+					log.Fatal().Err(err).Msg("Failed to connect to database")
+				}
+				defer dbPool.Close()
+				if err := ViewJobsByState(ctx, dbPool, state, queueN); err != nil {
+					log.Fatal().Err(err).Msgf("Failed to view %s jobs", state)
+				}
+			},
+		}
+		viewCmd.Flags().IntVarP(&queueN, "number", "n", 10, "Number of jobs to display")
+
+		countCmd := &cobra.Command{
+			Use:   "count",
+			Short: fmt.Sprintf("Count %s jobs", state),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				dbPool, err := NewDBPool(ctx, configPath)
+				if err != nil {
+					log.Fatal().Err(err).Msg("Failed to connect to database")
+				}
+				defer dbPool.Close()
+				if err := CountJobsByState(ctx, dbPool, state); err != nil {
+					log.Fatal().Err(err).Msgf("Failed to count %s jobs", state)
+				}
+			},
+		}
+		stateCmd.AddCommand(viewCmd, countCmd)
+
+		if state == rivertype.JobStateRunning || state == rivertype.JobStatePending {
+			return stateCmd
+		}
+
+		var yes bool
+		retryCmd := &cobra.Command{
+			Use:   "retry",
+			Short: fmt.Sprintf("Retry all %s jobs", state),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				dbPool, err := NewDBPool(ctx, configPath)
+				if err != nil {
+					log.Fatal().Err(err).Msg("Failed to connect to database")
+				}
+				defer dbPool.Close()
+
+				riverClient, err := newRiverClient(riverpgxv5.New(dbPool))
+				if err != nil {
+					log.Fatal().Err(err).Msg("Failed to create River client")
+				}
+
+				jobs, err := FindJobs(ctx, riverClient, JobFilter{States: []string{string(state)}}, nil)
+				if err != nil {
+					log.Fatal().Err(err).Msgf("Failed to list %s jobs", state)
+				}
+				if len(jobs) == 0 {
+					fmt.Printf("No %s jobs\n", state)
+					return
+				}
+				if !yes && !askForConfirmation(fmt.Sprintf("Are you sure you want to retry %d %s job(s)?", len(jobs), state)) {
+					log.Info().Msg("Bulk retry aborted by user")
+					return
+				}
+
+				ok := ApplyJobActionMany(ctx, riverClient, dbPool, JobActionRetry, jobs)
+				fmt.Printf("Retried %d of %d job(s)\n", ok, len(jobs))
+				if ok < len(jobs) {
+					dbPool.Close()
+					os.Exit(1)
+				}
+			},
+		}
+		retryCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt")
+		stateCmd.AddCommand(retryCmd)
+
+		return stateCmd
+	}
+
+	queueDiscardedCmd := newStateCmd(rivertype.JobStateDiscarded)
+	queueCancelledCmd := newStateCmd(rivertype.JobStateCancelled)
+	queueRetryableCmd := newStateCmd(rivertype.JobStateRetryable)
+	queueRunningCmd := newStateCmd(rivertype.JobStateRunning)
+	queuePendingCmd := newStateCmd(rivertype.JobStatePending)
+
+	queueStatsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show the number of jobs in each state",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+			if err := ViewQueueStats(ctx, dbPool); err != nil {
+				log.Fatal().Err(err).Msg("Failed to get queue stats")
+			}
+		},
+	}
+
+	queueCmd.AddCommand(
+		queueViewCmd, queueCountCmd, queueClearCmd, queueFailedCmd, queueCompletedCmd,
+		queueDiscardedCmd, queueCancelledCmd, queueRetryableCmd, queueRunningCmd, queuePendingCmd, queueStatsCmd,
+		queueShowCmd, queueRetryCmd, queueCancelCmd, queueDiscardCmd,
+	)
+
+	// ---- Serve subcommand ----
+	var serveAddr, serveToken string
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the HTTP JSON API",
+		Run: func(cmd *cobra.Command, args []string) {
+			serverConfig, err := LoadServerConfig(configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to load server config")
+			}
+			if serveAddr != "" {
+				serverConfig.Addr = serveAddr
+			}
+			if serveToken != "" {
+				serverConfig.Token = serveToken
+			}
+
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+
+			client, err := dprompts.NewClient(dbPool)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to create client")
+			}
+			if err := RunServer(ctx, dbPool, client, serverConfig); err != nil {
+				log.Fatal().Err(err).Msg("API server failed")
+			}
+		},
+	}
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "", "Address to listen on (default: [server] addr or 127.0.0.1:8080)")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "Bearer token required by the API (default: [server] token)")
+
+	// ---- Reduce subcommand ----
+	var reduceOpts ReduceOptions
+	reduceCmd := &cobra.Command{
+		Use:   "reduce",
+		Short: "Enqueue a map-reduce over every result of a group",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+
+			client, err := dprompts.NewClient(dbPool)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to create client")
+			}
+			if err := EnqueueReduce(ctx, dbPool, client, reduceOpts); err != nil {
+				log.Fatal().Err(err).Msg("Failed to enqueue reduction")
+			}
+		},
+	}
+	reduceCmd.Flags().StringVar(&reduceOpts.Group, "group", "", "Group to reduce (name or ID)")
+	reduceCmd.Flags().StringVar(&reduceOpts.PromptFile, "prompt", "", "File with the reduction instructions")
+	reduceCmd.Flags().StringVar(&reduceOpts.MergePromptFile, "merge-prompt", "", "File with instructions for combining partial results (default: --prompt)")
+	reduceCmd.Flags().StringVar(&reduceOpts.Into, "into", "", "Group for the final result (default: <group>-reduced)")
+	reduceCmd.Flags().IntVar(&reduceOpts.MaxChars, "max-chars", 12000, "Maximum characters of results per prompt, to fit the model context")
+	reduceCmd.Flags().IntVar(&reduceOpts.PartialChars, "partial-chars", 0, "Expected characters of one partial result, used to pack merge steps into --max-chars (default: max-chars / fan-in)")
+	reduceCmd.Flags().IntVar(&reduceOpts.FanIn, "fan-in", 8, "Most partial results combined per merge step")
+	reduceCmd.Flags().StringVar(&reduceOpts.Model, "model", "", "Model for the reduction jobs (default: [llm] model)")
+	_ = reduceCmd.MarkFlagRequired("group")
+	_ = reduceCmd.MarkFlagRequired("prompt")
+
+	// ---- Schedule subcommands ----
+	scheduleCmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Periodic schedules from the [[periodic]] config",
+	}
+
+	scheduleListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List configured schedules and their next run",
+		Run: func(cmd *cobra.Command, args []string) {
+			periodic, err := LoadPeriodicConfig(configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to load periodic schedules")
+			}
+			ListSchedules(periodic)
+		},
+	}
+
+	scheduleRunCmd := &cobra.Command{
+		Use:   "run <name>",
+		Short: "Enqueue a run of a schedule now",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			periodic, err := LoadPeriodicConfig(configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to load periodic schedules")
+			}
+
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+
+			riverClient, err := newRiverClient(riverpgxv5.New(dbPool))
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to create River client")
+			}
+			if err := RunScheduleNow(ctx, dbPool, riverClient, periodic, args[0]); err != nil {
+				log.Fatal().Err(err).Msg("Failed to run schedule")
+			}
+		},
+	}
+	scheduleCmd.AddCommand(scheduleListCmd, scheduleRunCmd)
 
 	// ---- Export subcommand ----
 	var (
@@ -347,8 +895,82 @@ func main() {
 		"Export all results (ignores --from-date)",
 	)
 
+	// ---- DB subcommands ----
+	var (
+		migrateTarget int
+		migrateSteps  int
+	)
+
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "Database operations",
+	}
+
+	dbMigrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the dPrompts and River schema",
+	}
+
+	dbMigrateUpCmd := &cobra.Command{
+		Use:   "up",
+		Short: "Apply River and pending dPrompts migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+			if err := MigrateUp(ctx, dbPool, migrateTarget); err != nil {
+				log.Fatal().Err(err).Msg("Migration failed")
+			}
+		},
+	}
+	dbMigrateUpCmd.Flags().IntVar(&migrateTarget, "target", 0, "Migrate up to this dPrompts version (default: latest)")
+
+	dbMigrateDownCmd := &cobra.Command{
+		Use:   "down",
+		Short: "Revert the latest dPrompts migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !askForConfirmation(fmt.Sprintf("Reverting %d migration(s) may drop tables and their data. Continue?", migrateSteps)) {
+				log.Info().Msg("Migration cancelled by user")
+				return
+			}
+
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+			if err := MigrateDown(ctx, dbPool, migrateSteps); err != nil {
+				log.Fatal().Err(err).Msg("Migration failed")
+			}
+		},
+	}
+	dbMigrateDownCmd.Flags().IntVar(&migrateSteps, "steps", 1, "Number of migrations to revert")
+
+	dbMigrateStatusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show applied and pending migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			dbPool, err := NewDBPool(ctx, configPath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to connect to database")
+			}
+			defer dbPool.Close()
+			if err := MigrationsStatus(ctx, dbPool); err != nil {
+				log.Fatal().Err(err).Msg("Failed to get migration status")
+			}
+		},
+	}
+
+	dbMigrateCmd.AddCommand(dbMigrateUpCmd, dbMigrateDownCmd, dbMigrateStatusCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+
 	// Add subcommands
-	rootCmd.AddCommand(clientCmd, workerCmd, viewCmd, queueCmd, exportCmd)
+	rootCmd.AddCommand(clientCmd, workerCmd, viewCmd, groupCmd, deleteGroupCmd, queueCmd, reduceCmd, scheduleCmd, serveCmd, exportCmd, dbCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal().Err(err).Msg("Command execution failed")
@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/HexmosTech/dPrompts/dprompts"
 	"github.com/dustin/go-humanize"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river/rivertype"
 	"github.com/rs/zerolog/log"
 )
 
@@ -29,7 +32,7 @@ func CountQueuedJobs(ctx context.Context, db *pgxpool.Pool) error {
 
 func ViewQueuedJobs(ctx context.Context, db *pgxpool.Pool, n int) error {
 	rows, err := db.Query(ctx, `
-		SELECT id, state, created_at, scheduled_at
+		SELECT id, state, `+dprompts.JobGroupSQL+`, created_at, scheduled_at
 		FROM river_job
 		WHERE state IN ('available', 'scheduled')
 		ORDER BY created_at DESC
@@ -43,13 +46,13 @@ func ViewQueuedJobs(ctx context.Context, db *pgxpool.Pool, n int) error {
 	fmt.Printf("Last %d queued jobs:\n", n)
 	for rows.Next() {
 		var id int64
-		var state string
+		var state, group string
 		var createdAt, scheduledAt time.Time
-		if err := rows.Scan(&id, &state, &createdAt, &scheduledAt); err != nil {
+		if err := rows.Scan(&id, &state, &group, &createdAt, &scheduledAt); err != nil {
 			return err
 		}
-		fmt.Printf("ID: %d | State: %s | CreatedAt: %s | ScheduledAt: %s\n",
-			id, state, createdAt.Format(time.RFC3339), scheduledAt.Format(time.RFC3339))
+		fmt.Printf("ID: %d | State: %s | Group: %s | CreatedAt: %s | ScheduledAt: %s\n",
+			id, state, displayGroup(group), createdAt.Format(time.RFC3339), scheduledAt.Format(time.RFC3339))
 	}
 	return rows.Err()
 }
@@ -181,13 +184,14 @@ func ViewJobsWithFailedAttempts(
 		Msg("Total jobs with failed attempts")
 
 	// --- Fetch limited recent failed jobs ---
-	const q = `
+	q := `
 		SELECT
 			id,
 			state,
 			attempt,
 			max_attempts,
 			kind,
+			` + dprompts.JobGroupSQL + `,
 			created_at,
 			attempted_at,
 			scheduled_at
@@ -218,6 +222,7 @@ func ViewJobsWithFailedAttempts(
 			attempt     int
 			maxAttempts int
 			kind        string
+			group       string
 			createdAt   time.Time
 			attemptedAt *time.Time
 			scheduledAt *time.Time
@@ -229,6 +234,7 @@ func ViewJobsWithFailedAttempts(
 			&attempt,
 			&maxAttempts,
 			&kind,
+			&group,
 			&createdAt,
 			&attemptedAt,
 			&scheduledAt,
@@ -254,6 +260,7 @@ func ViewJobsWithFailedAttempts(
 		log.Info().
 			Int64("job_id", id).
 			Str("kind", kind).
+			Str("group", displayGroup(group)).
 			Str("state", state).
 			Int("attempt", attempt).
 			Int("max_attempts", maxAttempts).
@@ -265,3 +272,137 @@ func ViewJobsWithFailedAttempts(
 
 	return rows.Err()
 }
+
+// stateTimeColumn is the timestamp most relevant to jobs in a state.
+func stateTimeColumn(state rivertype.JobState) (column, label string) {
+	switch state {
+	case rivertype.JobStateRunning:
+		return "attempted_at", "AttemptedAt"
+	case rivertype.JobStateCompleted, rivertype.JobStateCancelled, rivertype.JobStateDiscarded:
+		return "finalized_at", "FinalizedAt"
+	default:
+		return "scheduled_at", "ScheduledAt"
+	}
+}
+
+func CountJobsByState(ctx context.Context, db *pgxpool.Pool, state rivertype.JobState) error {
+	var count int64
+
+	err := db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM river_job
+		WHERE state = $1
+	`, state).Scan(&count)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Total %s jobs: %d\n", state, count)
+	return nil
+}
+
+// ViewJobsByState lists the latest n jobs in a state with their last error.
+func ViewJobsByState(ctx context.Context, db *pgxpool.Pool, state rivertype.JobState, n int) error {
+	column, label := stateTimeColumn(state)
+
+	rows, err := db.Query(ctx, `
+		SELECT
+			id,
+			kind,
+			`+dprompts.JobGroupSQL+`,
+			attempt,
+			max_attempts,
+			created_at,
+			`+column+`,
+			COALESCE(errors[array_length(errors, 1)]->>'error', '')
+		FROM river_job
+		WHERE state = $1
+		ORDER BY `+column+` DESC NULLS LAST, id DESC
+		LIMIT $2
+	`, state, n)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fmt.Printf("Last %d %s jobs:\n", n, state)
+	for rows.Next() {
+		var (
+			id          int64
+			kind, group string
+			attempt     int
+			maxAttempts int
+			createdAt   time.Time
+			at          *time.Time
+			lastError   string
+		)
+		if err := rows.Scan(&id, &kind, &group, &attempt, &maxAttempts, &createdAt, &at, &lastError); err != nil {
+			return err
+		}
+
+		fmt.Printf("ID: %d | Kind: %s | Group: %s | Attempts: %d/%d | CreatedAt: %s | %s: %s",
+			id, kind, displayGroup(group), attempt, maxAttempts, createdAt.Format(time.RFC3339), label, formatOptionalTime(at))
+		if lastError != "" {
+			fmt.Printf(" | LastError: %s", truncate(lastError, 120))
+		}
+		fmt.Println()
+	}
+	return rows.Err()
+}
+
+// ViewQueueStats prints the number of jobs in every River state on one line.
+func ViewQueueStats(ctx context.Context, db *pgxpool.Pool) error {
+	rows, err := db.Query(ctx, `
+		SELECT state::text, COUNT(*)
+		FROM river_job
+		GROUP BY state
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	counts := map[rivertype.JobState]int64{}
+	for rows.Next() {
+		var (
+			state string
+			count int64
+		)
+		if err := rows.Scan(&state, &count); err != nil {
+			return err
+		}
+		counts[rivertype.JobState(state)] = count
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var (
+		parts []string
+		total int64
+	)
+	for _, state := range rivertype.JobStates() {
+		parts = append(parts, fmt.Sprintf("%s: %d", state, counts[state]))
+		total += counts[state]
+	}
+	parts = append(parts, fmt.Sprintf("total: %d", total))
+
+	fmt.Println(strings.Join(parts, " | "))
+	return nil
+}
+
+func truncate(s string, max int) string {
+	r := []rune(strings.Join(strings.Fields(s), " "))
+	if len(r) <= max {
+		return string(r)
+	}
+	return string(r[:max]) + "..."
+}
+
+func displayGroup(group string) string {
+	if group == "" {
+		return "NULL"
+	}
+	return group
+}
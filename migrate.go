@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/riverqueue/river/rivermigrate"
+)
+
+// Migrations are embedded as migrations/NNN_name.up.sql and
+// migrations/NNN_name.down.sql pairs.
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	UpSQL   string
+	DownSQL string
+}
+
+// loadMigrations reads the migrations directory of fsys, normally
+// migrationFS, sorted by version.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, migName, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", name, err)
+		}
+
+		sql, err := fs.ReadFile(fsys, path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: migName}
+			byVersion[version] = m
+		}
+		if m.Name != migName {
+			return nil, fmt.Errorf("duplicate migration version %d: %q and %q", version, m.Name, migName)
+		}
+		dst := &m.UpSQL
+		if direction == "down" {
+			dst = &m.DownSQL
+		}
+		if *dst != "" {
+			return nil, fmt.Errorf("duplicate migration version %d: more than one %s file", version, direction)
+		}
+		*dst = string(sql)
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpSQL == "" || m.DownSQL == "" {
+			return nil, fmt.Errorf("migration %03d_%s is missing its up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func ensureMigrationsTable(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS dprompts_migrations (
+			version INT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	return err
+}
+
+func appliedMigrations(ctx context.Context, db *pgxpool.Pool) (map[int]time.Time, error) {
+	rows, err := db.Query(ctx, `SELECT version, applied_at FROM dprompts_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrateUp runs River's migrations and then every pending dPrompts
+// migration up to targetVersion (0 = latest), each in its own transaction.
+func MigrateUp(ctx context.Context, db *pgxpool.Pool, targetVersion int) error {
+	migrator, err := rivermigrate.New(riverpgxv5.New(db), nil)
+	if err != nil {
+		return err
+	}
+
+	riverRes, err := migrator.Migrate(ctx, rivermigrate.DirectionUp, nil)
+	if err != nil {
+		return fmt.Errorf("river migrations failed: %w", err)
+	}
+	for _, v := range riverRes.Versions {
+		fmt.Printf("Applied River migration %03d (%s) in %s\n", v.Version, v.Name, v.Duration.Round(time.Millisecond))
+	}
+
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return err
+	}
+
+	migrations, err := loadMigrations(migrationFS)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if targetVersion > 0 && m.Version > targetVersion {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		start := time.Now()
+		err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, m.UpSQL); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO dprompts_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %03d_%s failed: %w", m.Version, m.Name, err)
+		}
+
+		fmt.Printf("Applied dPrompts migration %03d (%s) in %s\n", m.Version, m.Name, time.Since(start).Round(time.Millisecond))
+		count++
+	}
+
+	if count == 0 {
+		fmt.Println("dPrompts schema is up to date")
+	}
+	return nil
+}
+
+// MigrateDown reverts the most recently applied dPrompts migrations, at most
+// steps of them. River's tables are left untouched.
+func MigrateDown(ctx context.Context, db *pgxpool.Pool, steps int) error {
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return err
+	}
+
+	migrations, err := loadMigrations(migrationFS)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		start := time.Now()
+		err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, m.DownSQL); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM dprompts_migrations WHERE version = $1`, m.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("reverting migration %03d_%s failed: %w", m.Version, m.Name, err)
+		}
+
+		fmt.Printf("Reverted dPrompts migration %03d (%s) in %s\n", m.Version, m.Name, time.Since(start).Round(time.Millisecond))
+		count++
+	}
+
+	if count == 0 {
+		fmt.Println("No applied dPrompts migrations to revert")
+	}
+	return nil
+}
+
+// MigrationsStatus prints River's applied version and every dPrompts
+// migration with the time it was applied.
+func MigrationsStatus(ctx context.Context, db *pgxpool.Pool) error {
+	migrator, err := rivermigrate.New(riverpgxv5.New(db), nil)
+	if err != nil {
+		return err
+	}
+
+	riverExisting, err := migrator.ExistingVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read River migrations: %w", err)
+	}
+	riverAll := migrator.AllVersions()
+
+	riverCurrent := 0
+	if len(riverExisting) > 0 {
+		riverCurrent = riverExisting[len(riverExisting)-1].Version
+	}
+	fmt.Printf("River: version %d of %d\n\n", riverCurrent, riverAll[len(riverAll)-1].Version)
+
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return err
+	}
+
+	migrations, err := loadMigrations(migrationFS)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("dPrompts migrations:")
+	pending := 0
+	for _, m := range migrations {
+		if at, ok := applied[m.Version]; ok {
+			fmt.Printf("%03d | %s | applied %s\n", m.Version, m.Name, at.Format(time.RFC3339))
+		} else {
+			fmt.Printf("%03d | %s | pending\n", m.Version, m.Name)
+			pending++
+		}
+	}
+
+	if pending > 0 {
+		fmt.Printf("\n%d pending migration(s). Run `dpr db migrate up` to apply.\n", pending)
+	}
+	return nil
+}
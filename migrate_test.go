@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	files := func(names ...string) fstest.MapFS {
+		fsys := fstest.MapFS{}
+		for _, name := range names {
+			fsys["migrations/"+name] = &fstest.MapFile{Data: []byte("-- " + name)}
+		}
+		return fsys
+	}
+
+	tests := []struct {
+		name         string
+		fsys         fstest.MapFS
+		wantVersions []int
+		wantErr      string
+	}{
+		{
+			name:         "up and down pairs",
+			fsys:         files("001_init.up.sql", "001_init.down.sql", "002_more.up.sql", "002_more.down.sql"),
+			wantVersions: []int{1, 2},
+		},
+		{
+			name:         "sorted by version, not by name",
+			fsys:         files("10_late.up.sql", "10_late.down.sql", "9_early.up.sql", "9_early.down.sql", "011_last.up.sql", "011_last.down.sql"),
+			wantVersions: []int{9, 10, 11},
+		},
+		{
+			name:         "other files ignored",
+			fsys:         files("001_init.up.sql", "001_init.down.sql", "README.md"),
+			wantVersions: []int{1},
+		},
+		{
+			name:    "missing down file",
+			fsys:    files("001_init.up.sql", "001_init.down.sql", "002_more.up.sql"),
+			wantErr: "002_more is missing its up or down file",
+		},
+		{
+			name:    "missing up file",
+			fsys:    files("001_init.down.sql"),
+			wantErr: "001_init is missing its up or down file",
+		},
+		{
+			name:    "duplicate version with another name",
+			fsys:    files("001_init.up.sql", "001_init.down.sql", "001_other.up.sql", "001_other.down.sql"),
+			wantErr: "duplicate migration version 1",
+		},
+		{
+			name:    "duplicate version written differently",
+			fsys:    files("1_init.up.sql", "001_init.up.sql", "001_init.down.sql"),
+			wantErr: "duplicate migration version 1",
+		},
+		{
+			name:    "no version",
+			fsys:    files("init.up.sql", "init.down.sql"),
+			wantErr: "invalid migration file name",
+		},
+		{
+			name:    "version not a number",
+			fsys:    files("v1_init.up.sql", "v1_init.down.sql"),
+			wantErr: "invalid migration version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.fsys)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadMigrations: %v", err)
+			}
+
+			var versions []int
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+				if m.UpSQL == "" || m.DownSQL == "" {
+					t.Errorf("migration %d has empty SQL", m.Version)
+				}
+			}
+			if !reflect.DeepEqual(versions, tt.wantVersions) {
+				t.Errorf("versions = %v, want %v", versions, tt.wantVersions)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFS)
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %03d_%s, want version %d", m.Version, m.Name, i+1)
+		}
+	}
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS dprompt_groups;
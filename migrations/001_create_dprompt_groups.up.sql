@@ -1,4 +1,4 @@
-CREATE TABLE dprompt_groups (
+CREATE TABLE IF NOT EXISTS dprompt_groups (
     id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
     group_name TEXT NOT NULL UNIQUE,
     created_at TIMESTAMPTZ DEFAULT NOW()
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS dprompts_results;
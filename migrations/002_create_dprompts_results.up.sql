@@ -1,4 +1,4 @@
-CREATE TABLE dprompts_results (
+CREATE TABLE IF NOT EXISTS dprompts_results (
     id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
     job_id BIGINT UNIQUE,
     response JSONB,
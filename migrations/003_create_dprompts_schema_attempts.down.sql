@@ -0,0 +1 @@
+DROP TABLE IF EXISTS dprompts_schema_attempts;
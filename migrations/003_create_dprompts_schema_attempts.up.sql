@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS dprompts_schema_attempts (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    job_id BIGINT NOT NULL,
+    subtask_index INT NOT NULL,
+    attempt INT NOT NULL,
+    output TEXT,
+    validation_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dprompts_schema_attempts_job ON dprompts_schema_attempts (job_id, subtask_index);
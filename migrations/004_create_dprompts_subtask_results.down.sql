@@ -0,0 +1 @@
+DROP TABLE IF EXISTS dprompts_subtask_results;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS dprompts_subtask_results (
+    job_id BIGINT NOT NULL,
+    subtask_index INT NOT NULL,
+    response TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (job_id, subtask_index)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE dprompts_subtask_results DROP COLUMN IF EXISTS usage;
+ALTER TABLE dprompts_results DROP COLUMN IF EXISTS usage;
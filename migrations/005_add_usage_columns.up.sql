@@ -0,0 +1,2 @@
+ALTER TABLE dprompts_results ADD COLUMN IF NOT EXISTS usage JSONB;
+ALTER TABLE dprompts_subtask_results ADD COLUMN IF NOT EXISTS usage JSONB;
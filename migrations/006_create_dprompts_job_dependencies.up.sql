@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS dprompts_job_dependencies (
+    job_id BIGINT NOT NULL,
+    depends_on_job_id BIGINT NOT NULL,
+    depends_on_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (job_id, depends_on_job_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dprompts_job_dependencies_upstream ON dprompts_job_dependencies (depends_on_job_id);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS dprompts_job_finalized ON river_job;
+DROP FUNCTION IF EXISTS dprompts_notify_job_finalized();
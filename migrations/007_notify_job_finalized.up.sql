@@ -0,0 +1,14 @@
+CREATE OR REPLACE FUNCTION dprompts_notify_job_finalized() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('dprompts_job_finalized', NEW.id::text || ':' || NEW.state::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS dprompts_job_finalized ON river_job;
+
+CREATE TRIGGER dprompts_job_finalized
+    AFTER UPDATE OF state ON river_job
+    FOR EACH ROW
+    WHEN (NEW.state IN ('completed', 'cancelled', 'discarded') AND OLD.state IS DISTINCT FROM NEW.state)
+    EXECUTE FUNCTION dprompts_notify_job_finalized();
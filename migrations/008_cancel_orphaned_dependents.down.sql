@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS dprompts_cancel_dependents ON river_job;
+DROP FUNCTION IF EXISTS dprompts_cancel_dependents();
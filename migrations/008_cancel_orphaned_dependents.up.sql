@@ -0,0 +1,44 @@
+CREATE OR REPLACE FUNCTION dprompts_cancel_dependents() RETURNS TRIGGER AS $$
+BEGIN
+    -- Cancelling a dependent fires this trigger again, so the whole rest of
+    -- the workflow is cancelled.
+    UPDATE river_job j
+    SET state = 'cancelled',
+        finalized_at = NOW(),
+        errors = array_append(j.errors, jsonb_build_object(
+            'at', NOW(),
+            'attempt', j.attempt,
+            'error', format('upstream job %s (key %s) was %s', NEW.id, d.depends_on_key, NEW.state),
+            'trace', ''
+        ))
+    FROM dprompts_job_dependencies d
+    WHERE d.depends_on_job_id = NEW.id
+      AND j.id = d.job_id
+      AND j.state = 'pending';
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS dprompts_cancel_dependents ON river_job;
+
+CREATE TRIGGER dprompts_cancel_dependents
+    AFTER UPDATE OF state ON river_job
+    FOR EACH ROW
+    WHEN (NEW.state IN ('cancelled', 'discarded') AND OLD.state IS DISTINCT FROM NEW.state)
+    EXECUTE FUNCTION dprompts_cancel_dependents();
+
+-- Workflows whose upstream job failed before this migration.
+UPDATE river_job j
+SET state = 'cancelled',
+    finalized_at = NOW(),
+    errors = array_append(j.errors, jsonb_build_object(
+        'at', NOW(),
+        'attempt', j.attempt,
+        'error', format('upstream job %s (key %s) was %s', u.id, d.depends_on_key, u.state),
+        'trace', ''
+    ))
+FROM dprompts_job_dependencies d
+JOIN river_job u ON u.id = d.depends_on_job_id
+WHERE j.id = d.job_id
+  AND j.state = 'pending'
+  AND u.state IN ('cancelled', 'discarded');
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS dprompts_job_finalized ON river_job;
+
+CREATE TRIGGER dprompts_job_finalized
+    AFTER UPDATE OF state ON river_job
+    FOR EACH ROW
+    WHEN (NEW.state IN ('completed', 'cancelled', 'discarded') AND OLD.state IS DISTINCT FROM NEW.state)
+    EXECUTE FUNCTION dprompts_notify_job_finalized();
@@ -0,0 +1,9 @@
+-- Also notify when an attempt fails and River schedules a retry, so
+-- waiting clients can report each attempt's error as it happens.
+DROP TRIGGER IF EXISTS dprompts_job_finalized ON river_job;
+
+CREATE TRIGGER dprompts_job_finalized
+    AFTER UPDATE OF state ON river_job
+    FOR EACH ROW
+    WHEN (NEW.state IN ('completed', 'cancelled', 'discarded', 'retryable') AND OLD.state IS DISTINCT FROM NEW.state)
+    EXECUTE FUNCTION dprompts_notify_job_finalized();
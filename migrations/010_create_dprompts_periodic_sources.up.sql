@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS dprompts_periodic_sources (
+    sha256 TEXT PRIMARY KEY,
+    path TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// MockProvider returns canned or schema-generated responses without
+// talking to a model, so pipelines can be tested on machines without a GPU.
+// Output and latency are derived from the configured seed and the request,
+// so the same job always produces the same output. Failures also mix in a
+// per-provider call counter, so a retried request can succeed and retries
+// and subtask resume get exercised.
+type MockProvider struct {
+	config        MockConfig
+	latency       time.Duration
+	latencyJitter time.Duration
+	calls         atomic.Int64
+}
+
+func NewMockProvider(llmConfig *LLMConfig) (*MockProvider, error) {
+	p := &MockProvider{config: llmConfig.Mock}
+
+	if p.config.FailureRate < 0 || p.config.FailureRate > 1 {
+		return nil, fmt.Errorf("mock failure_rate must be between 0 and 1, got %v", p.config.FailureRate)
+	}
+
+	if p.config.Latency != "" {
+		d, err := time.ParseDuration(p.config.Latency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mock latency: %w", err)
+		}
+		p.latency = d
+	}
+
+	if p.config.LatencyJitter != "" {
+		d, err := time.ParseDuration(p.config.LatencyJitter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mock latency_jitter: %w", err)
+		}
+		p.latencyJitter = d
+	}
+
+	return p, nil
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	seed := p.requestSeed(req)
+	rng := rand.New(rand.NewSource(seed))
+	failRng := rand.New(rand.NewSource(seed ^ p.calls.Add(1)))
+
+	delay := p.latency
+	if p.latencyJitter > 0 {
+		delay += time.Duration(rng.Int63n(int64(p.latencyJitter)))
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if p.config.FailureRate > 0 && failRng.Float64() < p.config.FailureRate {
+		return nil, fmt.Errorf("mock provider: simulated failure")
+	}
+
+	model := req.Model
+	if model == "" {
+		model = "mock"
+	}
+	resp := &ChatResponse{Model: model, Options: req.Options}
+
+	if p.config.Response != "" {
+		resp.Content = p.config.Response
+	} else {
+		var value any = map[string]any{"response": "mock response"}
+		if req.Schema != nil {
+			value = mockValueForSchema(req.Schema, rng)
+		}
+
+		out, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		resp.Content = string(out)
+	}
+
+	// Rough token counts (~4 characters per token) so usage views have data.
+	for _, m := range req.Messages {
+		resp.Usage.PromptEvalCount += len(m.Content)/4 + 1
+	}
+	resp.Usage.EvalCount = len(resp.Content)/4 + 1
+	resp.Usage.TotalDuration = int64(delay)
+
+	return resp, nil
+}
+
+func (p *MockProvider) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// requestSeed mixes the configured seed with the message contents.
+func (p *MockProvider) requestSeed(req ChatRequest) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d", p.config.Seed)
+	for _, m := range req.Messages {
+		h.Write([]byte(m.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(m.Content))
+		h.Write([]byte{0})
+	}
+	return int64(h.Sum64())
+}
+
+// mockValueForSchema builds a value that satisfies the common subset of
+// JSON Schema used in subtask schemas (type, properties, items, enum,
+// const, minItems, minLength, minimum).
+func mockValueForSchema(schema any, rng *rand.Rand) any {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if v, ok := s["const"]; ok {
+		return v
+	}
+	if enum, ok := s["enum"].([]any); ok && len(enum) > 0 {
+		return enum[rng.Intn(len(enum))]
+	}
+
+	typ := s["type"]
+	if types, ok := typ.([]any); ok && len(types) > 0 {
+		typ = types[0]
+	}
+	if typ == nil {
+		if _, ok := s["properties"]; ok {
+			typ = "object"
+		}
+	}
+
+	switch typ {
+	case "object":
+		obj := map[string]any{}
+		props, _ := s["properties"].(map[string]any)
+		keys := make([]string, 0, len(props))
+		for k := range props {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			obj[k] = mockValueForSchema(props[k], rng)
+		}
+		return obj
+
+	case "array":
+		n := 1
+		if minItems, ok := s["minItems"].(float64); ok && int(minItems) > n {
+			n = int(minItems)
+		}
+		if maxItems, ok := s["maxItems"].(float64); ok && int(maxItems) < n {
+			n = int(maxItems)
+		}
+		arr := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			arr = append(arr, mockValueForSchema(s["items"], rng))
+		}
+		return arr
+
+	case "string":
+		str := fmt.Sprintf("mock-%04d", rng.Intn(10000))
+		if minLength, ok := s["minLength"].(float64); ok {
+			for len(str) < int(minLength) {
+				str += "x"
+			}
+		}
+		if maxLength, ok := s["maxLength"].(float64); ok && len(str) > int(maxLength) {
+			str = str[:int(maxLength)]
+		}
+		return str
+
+	case "integer", "number":
+		n := 0.0
+		if min, ok := s["minimum"].(float64); ok {
+			n = min
+		}
+		if min, ok := s["exclusiveMinimum"].(float64); ok {
+			n = min + 1
+		}
+		return n
+
+	case "boolean":
+		return rng.Intn(2) == 1
+
+	case "null":
+		return nil
+
+	default:
+		return "mock"
+	}
+}
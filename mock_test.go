@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestMockProvider(t *testing.T, mock MockConfig) *MockProvider {
+	t.Helper()
+	p, err := NewMockProvider(&LLMConfig{Provider: "mock", Mock: mock})
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+	return p
+}
+
+func TestCallLLMWithMockProviderAndSchema(t *testing.T) {
+	schema := map[string]any{
+		"type":     "object",
+		"required": []any{"title", "tags", "score", "kind"},
+		"properties": map[string]any{
+			"title": map[string]any{"type": "string", "minLength": float64(12)},
+			"tags": map[string]any{
+				"type":     "array",
+				"minItems": float64(2),
+				"items":    map[string]any{"type": "string"},
+			},
+			"score": map[string]any{"type": "integer", "minimum": float64(3)},
+			"kind":  map[string]any{"enum": []any{"a", "b"}},
+		},
+	}
+
+	p := newTestMockProvider(t, MockConfig{Seed: 7})
+	args := DPromptsJobArgs{BasePrompt: "You are a test."}
+	sub := DPromptsSubTask{Prompt: "Describe something", Schema: schema}
+
+	res, err := CallLLM(context.Background(), p, args, sub, nil, 0)
+	if err != nil {
+		t.Fatalf("CallLLM: %v", err)
+	}
+	if res.NeededRepair() {
+		t.Errorf("mock output needed repair: %+v", res.Attempts)
+	}
+	if res.Calls != 1 || len(res.Attempts) != 1 {
+		t.Errorf("got %d calls and %d attempts, want 1 and 1", res.Calls, len(res.Attempts))
+	}
+	if res.Model != "mock" {
+		t.Errorf("model = %q, want mock", res.Model)
+	}
+	if res.Usage.EvalCount == 0 || res.Usage.PromptEvalCount == 0 {
+		t.Errorf("usage not filled in: %+v", res.Usage)
+	}
+
+	var out map[string]any
+	if err := json.Unmarshal([]byte(res.Content), &out); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if title, _ := out["title"].(string); len(title) < 12 {
+		t.Errorf("title %q shorter than minLength", title)
+	}
+	if tags, _ := out["tags"].([]any); len(tags) != 2 {
+		t.Errorf("tags = %v, want 2 items", out["tags"])
+	}
+
+	again, err := CallLLM(context.Background(), p, args, sub, nil, 0)
+	if err != nil {
+		t.Fatalf("CallLLM: %v", err)
+	}
+	if again.Content != res.Content {
+		t.Errorf("same request gave different output:\n%s\n%s", res.Content, again.Content)
+	}
+}
+
+func TestMockProviderFailuresAreNotPermanent(t *testing.T) {
+	p := newTestMockProvider(t, MockConfig{Seed: 1, FailureRate: 0.5})
+	req := ChatRequest{Messages: []ChatMessage{{Role: "user", Content: "same prompt"}}}
+
+	failed, succeeded := 0, 0
+	for i := 0; i < 50; i++ {
+		if _, err := p.Chat(context.Background(), req); err != nil {
+			failed++
+		} else {
+			succeeded++
+		}
+	}
+	if failed == 0 || succeeded == 0 {
+		t.Errorf("retries of one request: %d failed, %d succeeded; want both", failed, succeeded)
+	}
+}
+
+func TestMockProviderCannedResponse(t *testing.T) {
+	p := newTestMockProvider(t, MockConfig{Response: `{"answer":"ok"}`})
+	resp, err := p.Chat(context.Background(), ChatRequest{Model: "m", Messages: []ChatMessage{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Content != `{"answer":"ok"}` || resp.Model != "m" {
+		t.Errorf("got %q from %q", resp.Content, resp.Model)
+	}
+}
+
+func TestNewMockProviderRejectsInvalidConfig(t *testing.T) {
+	for _, mock := range []MockConfig{
+		{FailureRate: 1.5},
+		{FailureRate: -0.1},
+		{Latency: "soon"},
+		{LatencyJitter: "10"},
+	} {
+		if _, err := NewMockProvider(&LLMConfig{Mock: mock}); err == nil {
+			t.Errorf("NewMockProvider(%+v) succeeded, want error", mock)
+		}
+	}
+}
+
+func TestMockValueForSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema any
+		check  func(any) bool
+	}{
+		{"const", map[string]any{"const": "fixed"}, func(v any) bool { return v == "fixed" }},
+		{"enum", map[string]any{"enum": []any{"x"}}, func(v any) bool { return v == "x" }},
+		{"string min length", map[string]any{"type": "string", "minLength": float64(20)}, func(v any) bool {
+			s, ok := v.(string)
+			return ok && len(s) >= 20 && strings.HasPrefix(s, "mock-")
+		}},
+		{"string max length", map[string]any{"type": "string", "maxLength": float64(3)}, func(v any) bool {
+			s, ok := v.(string)
+			return ok && len(s) == 3
+		}},
+		{"integer minimum", map[string]any{"type": "integer", "minimum": float64(5)}, func(v any) bool { return v == 5.0 }},
+		{"number exclusive minimum", map[string]any{"type": "number", "exclusiveMinimum": float64(1)}, func(v any) bool { return v == 2.0 }},
+		{"boolean", map[string]any{"type": "boolean"}, func(v any) bool { _, ok := v.(bool); return ok }},
+		{"null", map[string]any{"type": "null"}, func(v any) bool { return v == nil }},
+		{"type list", map[string]any{"type": []any{"boolean", "null"}}, func(v any) bool { _, ok := v.(bool); return ok }},
+		{"array bounds", map[string]any{"type": "array", "minItems": float64(3), "items": map[string]any{"const": 1.0}}, func(v any) bool {
+			return reflect.DeepEqual(v, []any{1.0, 1.0, 1.0})
+		}},
+		{"object without type", map[string]any{"properties": map[string]any{"a": map[string]any{"const": "b"}}}, func(v any) bool {
+			return reflect.DeepEqual(v, map[string]any{"a": "b"})
+		}},
+		{"not a schema", "string", func(v any) bool { return v == nil }},
+		{"unknown type", map[string]any{}, func(v any) bool { return v == "mock" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mockValueForSchema(tt.schema, rand.New(rand.NewSource(1)))
+			if !tt.check(got) {
+				t.Errorf("mockValueForSchema(%v) = %#v", tt.schema, got)
+			}
+			if err := validateJSONAgainstSchemaValue(got, tt.schema); err != nil {
+				t.Errorf("generated value does not match its schema: %v", err)
+			}
+		})
+	}
+}
+
+// validateJSONAgainstSchemaValue validates a generated value the way the
+// worker validates model output.
+func validateJSONAgainstSchemaValue(v any, schema any) error {
+	if _, ok := schema.(map[string]any); !ok {
+		return nil
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return validateJSONAgainstSchema(string(out), schema)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/AlecAivazis/survey/v2"
@@ -9,6 +10,7 @@ import (
 	"github.com/santhosh-tekuri/jsonschema/v5"
 	"io"
 	"net/http"
+	"net/url"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -26,63 +28,66 @@ func LoadLLMConfig(configPath string) (*LLMConfig, error) {
 	return &conf.LLM, nil
 }
 
-func CallOllama(
-	prompt string,
-	schema interface{},
-	configPath string,
-	basePrompt string,
-) (string, error) {
+type OllamaProvider struct {
+	config *LLMConfig
+	client *http.Client
+}
 
-	// Load config
-	llmConfig, err := LoadLLMConfig(configPath)
-	if err != nil {
-		return "", err
+func NewOllamaProvider(llmConfig *LLMConfig) *OllamaProvider {
+	return &OllamaProvider{
+		config: llmConfig,
+		client: &http.Client{Timeout: 360 * time.Second},
 	}
+}
+
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+func (p *OllamaProvider) Chat(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	model, opts := withConfigDefaults(chatReq, p.config)
 
 	// Build request
 	req := map[string]any{
-		"model":  llmConfig.Model,
-		"stream": true,
-		"messages": []map[string]string{
-			{"role": "system", "content": basePrompt},
-			{"role": "user", "content": prompt},
-		},
-		"options": map[string]float64{
-			"temperature": llmConfig.Temperature,
-			"top_p":       llmConfig.TopP,
-		},
+		"model":    model,
+		"stream":   true,
+		"messages": chatReq.Messages,
+		"options":  opts,
 	}
 
-	if schema != nil {
-		req["format"] = schema
+	if chatReq.Schema != nil {
+		req["format"] = chatReq.Schema
 	} else {
 		req["format"] = "json"
 	}
 
 	reqBody, err := json.Marshal(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// HTTP call
-	client := &http.Client{Timeout: 360 * time.Second}
-	resp, err := client.Post(
-		llmConfig.APIEndpoint,
-		"application/json",
-		bytes.NewReader(reqBody),
-	)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.APIEndpoint, bytes.NewReader(reqBody))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ollama API returned %s", resp.Status)
+		return nil, fmt.Errorf("ollama API returned %s", resp.Status)
 	}
 
 	// Decode streamed JSON objects one by one
 	decoder := json.NewDecoder(resp.Body)
 
+	chatResp := &ChatResponse{Model: model, Options: opts}
+
 	var fullContent strings.Builder
 	for {
 		var chunk OllamaResponse
@@ -90,36 +95,51 @@ func CallOllama(
 			if err == io.EOF {
 				break
 			}
-			return "", err
+			return nil, err
 		}
 
 		fullContent.WriteString(chunk.Message.Content)
-	}
 
-	output := fullContent.String()
-
-	// schema validation (fail job if invalid)
-	if schema != nil {
-		if err := validateJSONAgainstSchema(output, schema); err != nil {
-			return "", err
+		if chunk.Done {
+			if chunk.Model != "" {
+				chatResp.Model = chunk.Model
+			}
+			chatResp.Usage = LLMUsage{
+				PromptEvalCount: chunk.PromptEvalCount,
+				EvalCount:       chunk.EvalCount,
+				TotalDuration:   chunk.TotalDuration,
+				LoadDuration:    chunk.LoadDuration,
+			}
 		}
 	}
 
-	return output, nil
+	chatResp.Content = fullContent.String()
+	return chatResp, nil
 }
 
-func isOllamaRunning() bool {
-	client := http.Client{
-		Timeout: 2 * time.Second,
+// HealthCheck queries /api/tags on the host serving the chat endpoint.
+func (p *OllamaProvider) HealthCheck(ctx context.Context) error {
+	tagsURL := "http://localhost:11434/api/tags"
+	if u, err := url.Parse(p.config.APIEndpoint); err == nil && u.Host != "" {
+		tagsURL = u.Scheme + "://" + u.Host + "/api/tags"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tagsURL, nil)
+	if err != nil {
+		return err
 	}
 
-	resp, err := client.Get("http://localhost:11434/api/tags")
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
 	if err != nil {
-		return false
+		return err
 	}
 	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusOK
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama API returned %s", resp.Status)
+	}
+	return nil
 }
 
 func hasSystemdOllama() bool {
@@ -224,19 +244,31 @@ func startOllama() error {
 	}
 }
 
-func waitForOllama(timeout time.Duration) error {
+// waitForOllama polls the provider's health check, so it waits on the
+// configured endpoint rather than the default port.
+func waitForOllama(ctx context.Context, provider Provider, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
 
+	var err error
 	for time.Now().Before(deadline) {
-		if isOllamaRunning() {
+		if err = provider.HealthCheck(ctx); err == nil {
 			return nil
 		}
 		time.Sleep(500 * time.Millisecond)
 	}
 
-	return fmt.Errorf("ollama did not start within %s", timeout)
+	return fmt.Errorf("ollama did not start within %s: %w", timeout, err)
 }
 
+// OutputValidationError means the model output did not satisfy the schema,
+// as opposed to the schema itself being unusable. Only these are repairable.
+type OutputValidationError struct {
+	Err error
+}
+
+func (e *OutputValidationError) Error() string { return e.Err.Error() }
+func (e *OutputValidationError) Unwrap() error { return e.Err }
+
 func validateJSONAgainstSchema(output string, schema any) error {
 	if schema == nil {
 		return nil
@@ -259,11 +291,11 @@ func validateJSONAgainstSchema(output string, schema any) error {
 
 	var instance any
 	if err := json.Unmarshal([]byte(output), &instance); err != nil {
-		return fmt.Errorf("output is not valid JSON: %w", err)
+		return &OutputValidationError{Err: fmt.Errorf("output is not valid JSON: %w", err)}
 	}
 
 	if err := compiledSchema.Validate(instance); err != nil {
-		return fmt.Errorf("schema validation failed: %w", err)
+		return &OutputValidationError{Err: fmt.Errorf("schema validation failed: %w", err)}
 	}
 
 	return nil
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIProvider talks to any server implementing the OpenAI
+// /v1/chat/completions protocol (llama.cpp server, vLLM, LM Studio, ...).
+type OpenAIProvider struct {
+	config *LLMConfig
+	client *http.Client
+}
+
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	// Only sent on the last chunk when stream_options.include_usage is set.
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func NewOpenAIProvider(llmConfig *LLMConfig) *OpenAIProvider {
+	return &OpenAIProvider{
+		config: llmConfig,
+		client: &http.Client{Timeout: 360 * time.Second},
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	model, opts := withConfigDefaults(chatReq, p.config)
+
+	// num_ctx has no equivalent in this protocol; the server decides it.
+	req := map[string]any{
+		"model":          model,
+		"stream":         true,
+		"stream_options": map[string]bool{"include_usage": true},
+		"messages":       chatReq.Messages,
+		"temperature":    *opts.Temperature,
+		"top_p":          *opts.TopP,
+	}
+	if opts.Seed != nil {
+		req["seed"] = *opts.Seed
+	}
+	if opts.NumPredict != nil {
+		req["max_tokens"] = *opts.NumPredict
+	}
+	if len(opts.Stop) > 0 {
+		req["stop"] = opts.Stop
+	}
+
+	if chatReq.Schema != nil {
+		strict := meetsStrictRules(chatReq.Schema)
+		if p.config.OpenAI.Strict != nil {
+			strict = *p.config.OpenAI.Strict
+		}
+		req["response_format"] = map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "dprompts_subtask",
+				"schema": chatReq.Schema,
+				"strict": strict,
+			},
+		}
+	} else if p.config.OpenAI.JSONObject {
+		req["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.APIEndpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	p.setAuth(httpReq)
+
+	start := time.Now()
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("openai API returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	chatResp := &ChatResponse{Model: model, Options: opts}
+	if err := readOpenAIStream(resp.Body, chatResp); err != nil {
+		return nil, err
+	}
+	// The protocol reports no server-side timings, so use the wall time.
+	chatResp.Usage.TotalDuration = int64(time.Since(start))
+
+	return chatResp, nil
+}
+
+// meetsStrictRules reports whether a schema can be used in strict mode:
+// every object lists all of its properties as required and sets
+// additionalProperties to false. Servers reject strict schemas that don't.
+func meetsStrictRules(schema any) bool {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		return true
+	}
+
+	props, hasProps := s["properties"].(map[string]any)
+	if hasProps || s["type"] == "object" {
+		if s["additionalProperties"] != false {
+			return false
+		}
+		required := map[string]bool{}
+		list, _ := s["required"].([]any)
+		for _, name := range list {
+			if name, ok := name.(string); ok {
+				required[name] = true
+			}
+		}
+		for name, prop := range props {
+			if !required[name] || !meetsStrictRules(prop) {
+				return false
+			}
+		}
+	}
+
+	if !meetsStrictRules(s["items"]) {
+		return false
+	}
+	for _, key := range []string{"anyOf", "allOf", "oneOf"} {
+		list, _ := s[key].([]any)
+		for _, sub := range list {
+			if !meetsStrictRules(sub) {
+				return false
+			}
+		}
+	}
+	for _, key := range []string{"$defs", "definitions"} {
+		defs, _ := s[key].(map[string]any)
+		for _, sub := range defs {
+			if !meetsStrictRules(sub) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readOpenAIStream concatenates the delta content of an SSE stream into
+// chatResp until the terminating "data: [DONE]" event, picking up the model
+// name and token usage on the way.
+func readOpenAIStream(r io.Reader, chatResp *ChatResponse) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	var fullContent strings.Builder
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("openai API stream error: %s", chunk.Error.Message)
+		}
+
+		for _, choice := range chunk.Choices {
+			fullContent.WriteString(choice.Delta.Content)
+		}
+		if chunk.Model != "" {
+			chatResp.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			chatResp.Usage.PromptEvalCount = chunk.Usage.PromptTokens
+			chatResp.Usage.EvalCount = chunk.Usage.CompletionTokens
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	chatResp.Content = fullContent.String()
+	return nil
+}
+
+// HealthCheck queries /v1/models next to the chat completions endpoint.
+func (p *OpenAIProvider) HealthCheck(ctx context.Context) error {
+	modelsURL := strings.TrimSuffix(p.config.APIEndpoint, "/chat/completions") + "/models"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelsURL, nil)
+	if err != nil {
+		return err
+	}
+	p.setAuth(req)
+
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("openai API returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *OpenAIProvider) setAuth(req *http.Request) {
+	if p.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+}
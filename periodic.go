@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/HexmosTech/dPrompts/dprompts"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultPeriodicDateFormat = "2006-01-02"
+
+// periodicNamePattern keeps names usable as River periodic job IDs.
+var periodicNamePattern = regexp.MustCompile(`^[\w][\w.\-]+$`)
+
+// PeriodicConfig is one [[periodic]] entry: a bulk file or inline job args
+// enqueued on a cron schedule into a group dated with the run time.
+type PeriodicConfig struct {
+	Name       string `toml:"name"`
+	Cron       string `toml:"cron"`
+	BulkFile   string `toml:"bulk_file"`
+	Args       string `toml:"args"`        // inline job args JSON
+	Group      string `toml:"group"`       // default: name
+	DateFormat string `toml:"date_format"` // Go layout, default 2006-01-02
+	Queue      string `toml:"queue"`
+	Priority   int    `toml:"priority"`
+	RunOnStart bool   `toml:"run_on_start"`
+
+	schedule cron.Schedule
+	jobs     string // contents of BulkFile
+	jobsSHA  string // hex SHA-256 of jobs
+}
+
+// LoadPeriodicConfig reads and validates the [[periodic]] entries. Bulk
+// files are read here, relative to the config file's directory; see
+// storePeriodicSources for how workers get their contents.
+func LoadPeriodicConfig(path string) ([]PeriodicConfig, error) {
+	var conf struct {
+		Periodic []PeriodicConfig `toml:"periodic"`
+	}
+	if _, err := toml.DecodeFile(path, &conf); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for i := range conf.Periodic {
+		p := &conf.Periodic[i]
+
+		if !periodicNamePattern.MatchString(p.Name) {
+			return nil, fmt.Errorf("periodic[%d]: name %q must be at least 2 letters, digits, '_', '-' or '.'", i, p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("periodic %q: duplicate name", p.Name)
+		}
+		seen[p.Name] = true
+
+		schedule, err := cron.ParseStandard(p.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("periodic %q: invalid cron %q: %w", p.Name, p.Cron, err)
+		}
+		p.schedule = schedule
+
+		if (p.BulkFile == "") == (p.Args == "") {
+			return nil, fmt.Errorf("periodic %q: set exactly one of bulk_file and args", p.Name)
+		}
+		if p.BulkFile != "" {
+			if !filepath.IsAbs(p.BulkFile) {
+				p.BulkFile = filepath.Join(filepath.Dir(path), p.BulkFile)
+			}
+			jobs, err := os.ReadFile(p.BulkFile)
+			if err != nil {
+				return nil, fmt.Errorf("periodic %q: %w", p.Name, err)
+			}
+			if strings.TrimSpace(string(jobs)) == "" {
+				return nil, fmt.Errorf("periodic %q: bulk file %s is empty", p.Name, p.BulkFile)
+			}
+			p.jobs = string(jobs)
+			sum := sha256.Sum256(jobs)
+			p.jobsSHA = hex.EncodeToString(sum[:])
+		}
+		if p.Args != "" {
+			var job dprompts.Job
+			if err := json.Unmarshal([]byte(p.Args), &job); err != nil {
+				return nil, fmt.Errorf("periodic %q: invalid args: %w", p.Name, err)
+			}
+		}
+
+		if p.Group == "" {
+			p.Group = p.Name
+		}
+		if p.DateFormat == "" {
+			p.DateFormat = defaultPeriodicDateFormat
+		}
+		if err := dprompts.ValidateGroupName(p.datedGroup(time.Now())); err != nil {
+			return nil, fmt.Errorf("periodic %q: %w", p.Name, err)
+		}
+		if err := dprompts.ValidateQueueAndPriority(p.Queue, p.Priority); err != nil {
+			return nil, fmt.Errorf("periodic %q: %w", p.Name, err)
+		}
+	}
+
+	return conf.Periodic, nil
+}
+
+// datedGroup is the group a run at t stores its results in.
+func (p *PeriodicConfig) datedGroup(t time.Time) string {
+	return p.Group + "-" + t.Format(p.DateFormat)
+}
+
+// Next is the next run after t.
+func (p *PeriodicConfig) Next(t time.Time) time.Time {
+	return p.schedule.Next(t)
+}
+
+// storePeriodicSources saves the contents of the entries' bulk files in
+// dprompts_periodic_sources, keyed by their SHA-256. Runs refer to a file
+// by that hash, so any worker can expand them without a copy of the file,
+// and each run gets the contents the schedule had when it was due.
+func storePeriodicSources(ctx context.Context, db *pgxpool.Pool, configs []PeriodicConfig) error {
+	for _, p := range configs {
+		if p.jobsSHA == "" {
+			continue
+		}
+		if _, err := db.Exec(ctx, `
+			INSERT INTO dprompts_periodic_sources (sha256, path, content)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (sha256) DO NOTHING
+		`, p.jobsSHA, p.BulkFile, p.jobs); err != nil {
+			return fmt.Errorf("periodic %q: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// jobArgs captures the entry as it is when a run is due, so the worker
+// expanding it does not depend on the config it was started with.
+func (p *PeriodicConfig) jobArgs(now time.Time) DPromptsPeriodicArgs {
+	return DPromptsPeriodicArgs{
+		Name:       p.Name,
+		BulkFile:   p.BulkFile,
+		BulkSHA256: p.jobsSHA,
+		Args:       p.Args,
+		GroupName:  p.datedGroup(now),
+		Queue:      p.Queue,
+		Priority:   p.Priority,
+	}
+}
+
+// DPromptsPeriodicArgs is the job River inserts on each tick of a schedule.
+// Working it enqueues the actual prompt jobs.
+type DPromptsPeriodicArgs struct {
+	Name       string `json:"name"`
+	BulkFile   string `json:"bulk_file,omitempty"`   // for logs; see BulkSHA256
+	BulkSHA256 string `json:"bulk_sha256,omitempty"` // key in dprompts_periodic_sources
+	Args       string `json:"args,omitempty"`
+	GroupName  string `json:"target_group"` // not group_name: this job has no result
+	Queue      string `json:"queue,omitempty"`
+	Priority   int    `json:"priority,omitempty"`
+}
+
+func (DPromptsPeriodicArgs) Kind() string {
+	return "dprompts_periodic"
+}
+
+// InsertOpts allows a few attempts. Work inserts the prompt jobs in the
+// transaction that completes the run, so a failed attempt enqueued none.
+func (DPromptsPeriodicArgs) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{MaxAttempts: 3}
+}
+
+type DPromptsPeriodicWorker struct {
+	river.WorkerDefaults[DPromptsPeriodicArgs]
+	db *pgxpool.Pool
+}
+
+func (w *DPromptsPeriodicWorker) Work(ctx context.Context, job *river.Job[DPromptsPeriodicArgs]) error {
+	args := job.Args
+
+	log.Info().
+		Int64("job_id", job.ID).
+		Str("schedule", args.Name).
+		Str("group_name", args.GroupName).
+		Msg("Enqueuing scheduled jobs")
+
+	client, err := dprompts.NewClient(w.db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := w.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	inserted := 1
+	if args.BulkSHA256 != "" {
+		var content string
+		err := tx.QueryRow(ctx,
+			`SELECT content FROM dprompts_periodic_sources WHERE sha256 = $1`, args.BulkSHA256,
+		).Scan(&content)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("schedule %q: contents of %s are not stored, start a worker or run `dpr schedule run` with its config", args.Name, args.BulkFile)
+		}
+		if err != nil {
+			return err
+		}
+		if inserted, err = client.EnqueueBulkFromTx(ctx, tx, strings.NewReader(content), args.enqueueOptions()); err != nil {
+			return fmt.Errorf("schedule %q: %w", args.Name, err)
+		}
+	} else {
+		var bulkJob dprompts.Job
+		if err := json.Unmarshal([]byte(args.Args), &bulkJob); err != nil {
+			return fmt.Errorf("invalid args for schedule %q: %w", args.Name, err)
+		}
+		if _, err := client.EnqueueTx(ctx, tx, bulkJob, args.enqueueOptions()); err != nil {
+			return fmt.Errorf("schedule %q: %w", args.Name, err)
+		}
+	}
+
+	// Completing the run in the same transaction enqueues its jobs exactly
+	// once: either both commit, or the run is retried with nothing enqueued.
+	if _, err := river.JobCompleteTx[*riverpgxv5.Driver](ctx, tx, job); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	log.Info().Str("schedule", args.Name).Int("jobs", inserted).Msg("Enqueued scheduled jobs")
+	return nil
+}
+
+func (a DPromptsPeriodicArgs) enqueueOptions() dprompts.EnqueueOptions {
+	return dprompts.EnqueueOptions{
+		GroupName:  a.GroupName,
+		ForceGroup: true,
+		Queue:      a.Queue,
+		Priority:   a.Priority,
+	}
+}
+
+// periodicJobs turns the [[periodic]] entries into River periodic jobs.
+func periodicJobs(configs []PeriodicConfig) []*river.PeriodicJob {
+	jobs := make([]*river.PeriodicJob, 0, len(configs))
+	for i := range configs {
+		p := &configs[i]
+		jobs = append(jobs, river.NewPeriodicJob(
+			p.schedule,
+			func() (river.JobArgs, *river.InsertOpts) {
+				return p.jobArgs(time.Now()), nil
+			},
+			&river.PeriodicJobOpts{ID: p.Name, RunOnStart: p.RunOnStart},
+		))
+	}
+	return jobs
+}
+
+// ListSchedules prints every [[periodic]] entry with its next run.
+func ListSchedules(configs []PeriodicConfig) {
+	if len(configs) == 0 {
+		fmt.Println("No [[periodic]] schedules configured")
+		return
+	}
+
+	now := time.Now()
+	for i := range configs {
+		p := &configs[i]
+		source := p.BulkFile
+		if source == "" {
+			source = "inline args"
+		}
+		next := p.Next(now)
+		fmt.Printf("Name: %s | Cron: %s | Source: %s | Next: %s | Group: %s\n",
+			p.Name, p.Cron, source, next.Format(time.RFC3339), p.datedGroup(next))
+	}
+}
+
+// RunScheduleNow enqueues one run of the named schedule for the workers.
+func RunScheduleNow(ctx context.Context, db *pgxpool.Pool, riverClient *river.Client[pgx.Tx], configs []PeriodicConfig, name string) error {
+	for i := range configs {
+		p := &configs[i]
+		if p.Name != name {
+			continue
+		}
+		if err := storePeriodicSources(ctx, db, configs[i:i+1]); err != nil {
+			return err
+		}
+
+		args := p.jobArgs(time.Now())
+		res, err := riverClient.Insert(ctx, args, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Enqueued run of %q as job %d | Group: %s\n", p.Name, res.Job.ID, args.GroupName)
+		return nil
+	}
+	return fmt.Errorf("no schedule named %q", name)
+}
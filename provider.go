@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Provider is an LLM backend the worker can send subtasks to.
+type Provider interface {
+	// Name returns the provider key used in the [llm] config section.
+	Name() string
+	// Chat sends the messages to the model and returns the full reply.
+	// When req.Schema is set the provider must ask the backend for
+	// structured output matching that schema.
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	// HealthCheck reports whether the backend is reachable.
+	HealthCheck(ctx context.Context) error
+}
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	Messages []ChatMessage
+	Schema   interface{}
+	Model    string     // empty = [llm] model
+	Options  LLMOptions // nil fields = [llm] defaults
+}
+
+type ChatResponse struct {
+	Content string
+	Model   string     // model actually used
+	Options LLMOptions // options actually sent
+	Usage   LLMUsage
+}
+
+// NewProvider builds the provider selected by the `provider` key in the
+// [llm] config section. An empty value keeps the original Ollama backend.
+func NewProvider(llmConfig *LLMConfig) (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(llmConfig.Provider)) {
+	case "", "ollama":
+		return NewOllamaProvider(llmConfig), nil
+	case "openai":
+		return NewOpenAIProvider(llmConfig), nil
+	case "mock":
+		return NewMockProvider(llmConfig)
+	default:
+		return nil, fmt.Errorf("unknown llm provider %q", llmConfig.Provider)
+	}
+}
+
+// SchemaAttempt is one structured-output attempt of a subtask.
+type SchemaAttempt struct {
+	Attempt         int
+	Output          string
+	ValidationError string // empty when the output was valid
+}
+
+type LLMCallResult struct {
+	Content  string
+	Attempts []SchemaAttempt // every attempt for subtasks with a schema
+	Model    string
+	Options  LLMOptions
+	Usage    LLMUsage // summed over all calls
+	Calls    int
+}
+
+// NeededRepair reports whether the first attempt failed validation.
+func (r *LLMCallResult) NeededRepair() bool {
+	return len(r.Attempts) > 0 && r.Attempts[0].ValidationError != ""
+}
+
+// CallLLM runs a single subtask prompt, after any earlier conversation
+// turns in history, against the provider and validates
+// the output against the subtask schema. Invalid output is sent back to the
+// model with the validation error up to repairAttempts times before the
+// subtask fails. The returned result is non-nil even on error so the
+// attempts can be recorded.
+func CallLLM(
+	ctx context.Context,
+	provider Provider,
+	args DPromptsJobArgs,
+	sub DPromptsSubTask,
+	history []ChatMessage,
+	repairAttempts int,
+) (*LLMCallResult, error) {
+	result := &LLMCallResult{}
+
+	messages := make([]ChatMessage, 0, len(history)+2)
+	messages = append(messages, ChatMessage{Role: "system", Content: args.BasePrompt})
+	messages = append(messages, history...)
+	messages = append(messages, ChatMessage{Role: "user", Content: sub.Prompt})
+
+	req := ChatRequest{
+		Messages: messages,
+		Schema:   sub.Schema,
+		Model:    resolveModel(args, sub),
+		Options:  mergeLLMOptions(args.Options, sub.Options),
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := provider.Chat(ctx, req)
+		if err != nil {
+			return result, err
+		}
+		result.Calls++
+		result.Model = resp.Model
+		result.Options = resp.Options
+		result.Usage.Add(resp.Usage)
+
+		if sub.Schema == nil {
+			result.Content = resp.Content
+			return result, nil
+		}
+
+		err = validateJSONAgainstSchema(resp.Content, sub.Schema)
+
+		record := SchemaAttempt{Attempt: attempt, Output: resp.Content}
+		if err != nil {
+			record.ValidationError = err.Error()
+		}
+		result.Attempts = append(result.Attempts, record)
+
+		if err == nil {
+			result.Content = resp.Content
+			return result, nil
+		}
+
+		var invalid *OutputValidationError
+		if !errors.As(err, &invalid) || attempt >= repairAttempts {
+			return result, err
+		}
+
+		req.Messages = append(req.Messages,
+			ChatMessage{Role: "assistant", Content: resp.Content},
+			ChatMessage{Role: "user", Content: repairPrompt(err)},
+		)
+	}
+}
+
+func repairPrompt(validationErr error) string {
+	return "Your previous response does not match the required JSON schema:\n" +
+		validationErr.Error() +
+		"\nReply again with only the corrected JSON that satisfies the schema."
+}
+
+// resolveModel returns the subtask model, else the job model, else "" so
+// the provider uses the [llm] model.
+func resolveModel(args DPromptsJobArgs, sub DPromptsSubTask) string {
+	if sub.Model != "" {
+		return sub.Model
+	}
+	return args.Model
+}
+
+// mergeLLMOptions layers the given overrides in order; later ones win.
+func mergeLLMOptions(layers ...*LLMOptions) LLMOptions {
+	var merged LLMOptions
+	for _, o := range layers {
+		if o == nil {
+			continue
+		}
+		if o.Temperature != nil {
+			merged.Temperature = o.Temperature
+		}
+		if o.TopP != nil {
+			merged.TopP = o.TopP
+		}
+		if o.Seed != nil {
+			merged.Seed = o.Seed
+		}
+		if o.NumCtx != nil {
+			merged.NumCtx = o.NumCtx
+		}
+		if o.NumPredict != nil {
+			merged.NumPredict = o.NumPredict
+		}
+		if o.Stop != nil {
+			merged.Stop = o.Stop
+		}
+	}
+	return merged
+}
+
+// withConfigDefaults fills model, temperature and top_p from the [llm]
+// config where the request did not override them.
+func withConfigDefaults(req ChatRequest, llmConfig *LLMConfig) (string, LLMOptions) {
+	model := req.Model
+	if model == "" {
+		model = llmConfig.Model
+	}
+
+	opts := req.Options
+	if opts.Temperature == nil {
+		t := llmConfig.Temperature
+		opts.Temperature = &t
+	}
+	if opts.TopP == nil {
+		p := llmConfig.TopP
+		opts.TopP = &p
+	}
+	return model, opts
+}
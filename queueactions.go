@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/HexmosTech/dPrompts/dprompts"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/rs/zerolog/log"
+)
+
+type JobAction string
+
+const (
+	JobActionRetry   JobAction = "retry"
+	JobActionCancel  JobAction = "cancel"
+	JobActionDiscard JobAction = "discard"
+)
+
+// defaultStates are the states a bulk action looks at when no --state is
+// given: the ones where the action makes sense.
+func (a JobAction) defaultStates() []rivertype.JobState {
+	switch a {
+	case JobActionRetry:
+		return []rivertype.JobState{rivertype.JobStateDiscarded, rivertype.JobStateCancelled, rivertype.JobStateRetryable}
+	case JobActionCancel:
+		return []rivertype.JobState{rivertype.JobStateAvailable, rivertype.JobStateScheduled, rivertype.JobStateRetryable, rivertype.JobStatePending, rivertype.JobStateRunning}
+	default:
+		return []rivertype.JobState{rivertype.JobStateAvailable, rivertype.JobStateScheduled, rivertype.JobStateRetryable, rivertype.JobStatePending}
+	}
+}
+
+// JobFilter selects jobs for bulk queue actions. Empty fields match all.
+type JobFilter struct {
+	States       []string
+	Group        string
+	Kind         string
+	Metadata     map[string]string // top-level metadata key = value
+	MetadataKeys []string          // top-level metadata keys that must be present
+}
+
+func (f JobFilter) IsEmpty() bool {
+	return len(f.States) == 0 && f.Group == "" && f.Kind == "" && len(f.Metadata) == 0 && len(f.MetadataKeys) == 0
+}
+
+// ParseMetadataFilters adds --meta flags to the filter: "key=value" matches
+// a value, a bare "key" matches any job that has the key.
+func (f *JobFilter) ParseMetadataFilters(filters []string) error {
+	for _, filter := range filters {
+		k, v, hasValue := strings.Cut(filter, "=")
+		if k == "" {
+			return fmt.Errorf("invalid metadata filter %q, expected key or key=value", filter)
+		}
+		if !hasValue {
+			f.MetadataKeys = append(f.MetadataKeys, k)
+			continue
+		}
+		if f.Metadata == nil {
+			f.Metadata = map[string]string{}
+		}
+		f.Metadata[k] = v
+	}
+	return nil
+}
+
+func (f JobFilter) listParams(defaultStates []rivertype.JobState) (*river.JobListParams, error) {
+	params := river.NewJobListParams().First(1000)
+
+	states := defaultStates
+	if len(f.States) > 0 {
+		states = make([]rivertype.JobState, 0, len(f.States))
+		for _, s := range f.States {
+			state := rivertype.JobState(s)
+			if !isValidJobState(state) {
+				return nil, fmt.Errorf("unknown job state %q", s)
+			}
+			states = append(states, state)
+		}
+	}
+	params = params.States(states...)
+
+	if f.Kind != "" {
+		params = params.Kinds(f.Kind)
+	}
+	if f.Group != "" {
+		params = params.Where(dprompts.JobGroupSQL+" = @group_name", river.NamedArgs{"group_name": f.Group})
+	}
+	if len(f.Metadata) > 0 {
+		fragment, err := json.Marshal(f.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		params = params.Metadata(string(fragment))
+	}
+	for i, key := range f.MetadataKeys {
+		name := fmt.Sprintf("metadata_key_%d", i)
+		params = params.Where("metadata ? @"+name, river.NamedArgs{name: key})
+	}
+
+	return params, nil
+}
+
+func isValidJobState(state rivertype.JobState) bool {
+	for _, s := range rivertype.JobStates() {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// FindJobs lists every job matching the filter, following River's cursor.
+func FindJobs(ctx context.Context, riverClient *river.Client[pgx.Tx], filter JobFilter, defaultStates []rivertype.JobState) ([]*rivertype.JobRow, error) {
+	params, err := filter.listParams(defaultStates)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*rivertype.JobRow
+	for {
+		res, err := riverClient.JobList(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, res.Jobs...)
+		if len(res.Jobs) == 0 || res.LastCursor == nil {
+			break
+		}
+		params = params.After(res.LastCursor)
+	}
+
+	return jobs, nil
+}
+
+// ApplyJobAction retries, cancels or discards a single job. Retry and
+// cancel go through River's client so running workers are notified;
+// River has no discard API, so discard finalizes a job that is not
+// running the same way River does when attempts are exhausted.
+func ApplyJobAction(ctx context.Context, riverClient *river.Client[pgx.Tx], db *pgxpool.Pool, action JobAction, jobID int64) (*rivertype.JobRow, error) {
+	switch action {
+	case JobActionRetry:
+		return retryJob(ctx, riverClient, db, jobID)
+	case JobActionCancel:
+		return riverClient.JobCancel(ctx, jobID)
+	case JobActionDiscard:
+		return discardJob(ctx, riverClient, db, jobID)
+	default:
+		return nil, fmt.Errorf("unknown job action %q", action)
+	}
+}
+
+// retryJob retries a job through River. A workflow job is only made
+// available once the jobs it depends on have results; running it earlier
+// would fail every attempt. Until then a cancelled or discarded workflow
+// job goes back to pending, and its upstream jobs release it.
+func retryJob(ctx context.Context, riverClient *river.Client[pgx.Tx], db *pgxpool.Pool, jobID int64) (*rivertype.JobRow, error) {
+	var hasDependencies bool
+	if err := db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM dprompts_job_dependencies WHERE job_id = $1)`, jobID,
+	).Scan(&hasDependencies); err != nil {
+		return nil, err
+	}
+	if !hasDependencies {
+		return riverClient.JobRetry(ctx, jobID)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx) // safe no-op if already committed
+	}()
+
+	var state rivertype.JobState
+	err = tx.QueryRow(ctx, `SELECT state::text FROM river_job WHERE id = $1 FOR UPDATE`, jobID).Scan((*string)(&state))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, rivertype.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if state == rivertype.JobStateRunning {
+		return riverClient.JobGet(ctx, jobID)
+	}
+
+	released, err := dprompts.ReleaseIfReady(ctx, tx, riverClient, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !released {
+		if state == rivertype.JobStatePending {
+			return nil, fmt.Errorf("job %d is waiting for jobs that have no result yet", jobID)
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE river_job
+			SET state = 'pending',
+				finalized_at = NULL
+			WHERE id = $1
+		`, jobID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return riverClient.JobGet(ctx, jobID)
+}
+
+func discardJob(ctx context.Context, riverClient *river.Client[pgx.Tx], db *pgxpool.Pool, jobID int64) (*rivertype.JobRow, error) {
+	job, err := riverClient.JobGet(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch job.State {
+	case rivertype.JobStateRunning:
+		return nil, fmt.Errorf("job %d is running; cancel it instead", jobID)
+	case rivertype.JobStateCompleted, rivertype.JobStateCancelled, rivertype.JobStateDiscarded:
+		return job, nil // already finalized, nothing to do
+	}
+
+	_, err = db.Exec(ctx, `
+		UPDATE river_job
+		SET state = 'discarded',
+			finalized_at = NOW()
+		WHERE id = $1
+		  AND state NOT IN ('running', 'completed', 'cancelled', 'discarded')
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	return riverClient.JobGet(ctx, jobID)
+}
+
+// ApplyJobActionMany applies the action to every job and logs each outcome.
+// It returns how many jobs succeeded.
+func ApplyJobActionMany(ctx context.Context, riverClient *river.Client[pgx.Tx], db *pgxpool.Pool, action JobAction, jobs []*rivertype.JobRow) int {
+	ok := 0
+	for _, j := range jobs {
+		row, err := ApplyJobAction(ctx, riverClient, db, action, j.ID)
+		if err != nil {
+			if errors.Is(err, rivertype.ErrNotFound) {
+				err = fmt.Errorf("job no longer exists")
+			}
+			log.Error().Err(err).Int64("job_id", j.ID).Str("action", string(action)).Msg("Job action failed")
+			continue
+		}
+		ok++
+		log.Info().
+			Int64("job_id", row.ID).
+			Str("action", string(action)).
+			Str("state", string(row.State)).
+			Msg("Job updated")
+	}
+	return ok
+}
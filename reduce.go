@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/HexmosTech/dPrompts/dprompts"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+type ReduceOptions struct {
+	Group           string // group to reduce, by name or ID
+	PromptFile      string // instructions for every reduction step
+	MergePromptFile string // instructions for combining partial results (default: PromptFile)
+	Into            string // group of the final result (default: <group>-reduced)
+	MaxChars        int    // rough size budget of the items in one prompt
+	PartialChars    int    // expected size of one partial result (default: MaxChars / FanIn)
+	FanIn           int    // most partial results combined per merge job
+	Model           string
+}
+
+// EnqueueReduce enqueues a map-reduce workflow over every result of a
+// group: the results are split into chunks of at most MaxChars, each chunk
+// is reduced by one job, and the partial results are merged, as many as
+// fit in MaxChars and at most FanIn at a time, until one job is left.
+// Partial results are stored in <into>-partials and the final result in
+// the Into group.
+func EnqueueReduce(ctx context.Context, db *pgxpool.Pool, client *dprompts.Client, opts ReduceOptions) error {
+	if opts.MaxChars <= 0 {
+		return fmt.Errorf("max chars must be positive")
+	}
+	if opts.FanIn < 2 {
+		return fmt.Errorf("fan-in must be at least 2")
+	}
+	partialChars := opts.PartialChars
+	if partialChars == 0 {
+		partialChars = opts.MaxChars / opts.FanIn
+	}
+	// Two partial results must fit in one merge job for the tree to shrink.
+	if partialChars <= 0 || partialChars > opts.MaxChars/2 {
+		return fmt.Errorf("partial chars must be between 1 and half of max chars (%d)", opts.MaxChars/2)
+	}
+
+	prompt, err := os.ReadFile(opts.PromptFile)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt: %w", err)
+	}
+	mergePrompt := prompt
+	if opts.MergePromptFile != "" {
+		if mergePrompt, err = os.ReadFile(opts.MergePromptFile); err != nil {
+			return fmt.Errorf("failed to read merge prompt: %w", err)
+		}
+	}
+
+	groupID, groupName, err := resolveGroupRef(ctx, db, opts.Group)
+	if err != nil {
+		return err
+	}
+	into := opts.Into
+	if into == "" {
+		into = groupName + "-reduced"
+	}
+	partials := into + "-partials"
+	for _, name := range []string{into, partials} {
+		if err := dprompts.ValidateGroupName(name); err != nil {
+			return err
+		}
+	}
+
+	items, err := loadGroupItems(ctx, db, groupID)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("group %q has no results", groupName)
+	}
+
+	chunks := chunkItems(items, opts.MaxChars)
+	jobs := buildReduceJobs(chunks, strings.TrimSpace(string(prompt)), strings.TrimSpace(string(mergePrompt)),
+		opts.MaxChars, partialChars, opts.FanIn, opts.Model)
+
+	// The last job is the root of the tree.
+	for i := range jobs {
+		jobs[i].GroupName = partials
+	}
+	jobs[len(jobs)-1].GroupName = into
+
+	if _, err := client.EnqueueBulk(ctx, jobs, dprompts.EnqueueOptions{}); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("group_name", groupName).
+		Int("results", len(items)).
+		Int("chunks", len(chunks)).
+		Int("jobs", len(jobs)).
+		Str("into", into).
+		Msg("Enqueued reduction")
+	fmt.Printf("Reducing %d results of %q in %d chunk(s) with %d job(s). Final result: group %q (partials: %q)\n",
+		len(items), groupName, len(chunks), len(jobs), into, partials)
+	return nil
+}
+
+// loadGroupItems returns the group's results as prompt text, oldest first.
+func loadGroupItems(ctx context.Context, db *pgxpool.Pool, groupID int) ([]string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT response
+		FROM dprompts_results
+		WHERE group_id = $1
+		ORDER BY id
+	`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []string
+	for rows.Next() {
+		var response []byte
+		if err := rows.Scan(&response); err != nil {
+			return nil, err
+		}
+
+		var outputs map[string]string
+		if err := json.Unmarshal(response, &outputs); err != nil {
+			items = append(items, string(response))
+			continue
+		}
+		if len(outputs) == 1 {
+			for _, output := range outputs {
+				items = append(items, output)
+			}
+			continue
+		}
+
+		var b strings.Builder
+		for _, key := range sortedSubtaskKeys(outputs) {
+			fmt.Fprintf(&b, "%s: %s\n", key, outputs[key])
+		}
+		items = append(items, strings.TrimRight(b.String(), "\n"))
+	}
+	return items, rows.Err()
+}
+
+// chunkItems packs items into chunks of at most maxChars. An item larger
+// than maxChars gets a chunk of its own.
+func chunkItems(items []string, maxChars int) [][]string {
+	sizes := make([]int, len(items))
+	for i, item := range items {
+		if len(item) > maxChars {
+			log.Warn().Int("chars", len(item)).Int("max_chars", maxChars).Msg("Result is larger than one chunk")
+		}
+		sizes[i] = len(item)
+	}
+
+	var chunks [][]string
+	for _, r := range packRanges(sizes, maxChars, 0) {
+		chunks = append(chunks, items[r[0]:r[1]])
+	}
+	return chunks
+}
+
+// packRanges splits items of the given sizes into consecutive [start, end)
+// ranges of at most maxChars and, if maxItems > 0, at most maxItems items.
+// An item larger than maxChars gets a range of its own.
+func packRanges(sizes []int, maxChars, maxItems int) [][2]int {
+	var (
+		ranges [][2]int
+		start  int
+		size   int
+	)
+	for i, n := range sizes {
+		full := size+n > maxChars || (maxItems > 0 && i-start == maxItems)
+		if i > start && full {
+			ranges = append(ranges, [2]int{start, i})
+			start, size = i, 0
+		}
+		size += n
+	}
+	if start < len(sizes) {
+		ranges = append(ranges, [2]int{start, len(sizes)})
+	}
+	return ranges
+}
+
+// buildReduceJobs returns one map job per chunk and the merge levels above
+// them, in dependency order. The size of a partial result is not known
+// until it exists, so each is estimated as the size of what it reduces,
+// capped at partialChars, and merge levels are packed into maxChars by
+// those estimates, at most fanIn partial results per job. A partial result
+// left on its own is passed up to the next level.
+func buildReduceJobs(chunks [][]string, prompt, mergePrompt string, maxChars, partialChars, fanIn int, model string) []dprompts.Job {
+	type node struct {
+		key  string
+		size int
+	}
+	var (
+		jobs  []dprompts.Job
+		level []node
+	)
+
+	for i, chunk := range chunks {
+		var b strings.Builder
+		b.WriteString("Items:")
+		size := 0
+		for j, item := range chunk {
+			fmt.Fprintf(&b, "\n\n[%d]\n%s", j+1, dprompts.EscapePlaceholders(item))
+			size += len(item)
+		}
+
+		key := fmt.Sprintf("map-%d", i)
+		jobs = append(jobs, dprompts.Job{
+			Key:        key,
+			BasePrompt: prompt,
+			Model:      model,
+			SubTasks:   []DPromptsSubTask{{Prompt: b.String()}},
+		})
+		level = append(level, node{key, min(size, partialChars)})
+	}
+
+	for depth := 1; len(level) > 1; depth++ {
+		sizes := make([]int, len(level))
+		for i, n := range level {
+			sizes[i] = n.size
+		}
+
+		var next []node
+		merges := 0
+		for _, r := range packRanges(sizes, maxChars, fanIn) {
+			// Merging a single partial result would only rewrite it.
+			if r[1]-r[0] == 1 {
+				next = append(next, level[r[0]])
+				continue
+			}
+
+			var (
+				b    strings.Builder
+				deps []string
+				size int
+			)
+			b.WriteString("Combine these partial results into one:")
+			for j, dep := range level[r[0]:r[1]] {
+				fmt.Fprintf(&b, "\n\n[%d]\n{{job.%s}}", j+1, dep.key)
+				deps = append(deps, dep.key)
+				size += dep.size
+			}
+
+			key := fmt.Sprintf("reduce-%d-%d", depth, merges)
+			merges++
+			jobs = append(jobs, dprompts.Job{
+				Key:        key,
+				DependsOn:  deps,
+				BasePrompt: mergePrompt,
+				Model:      model,
+				SubTasks:   []DPromptsSubTask{{Prompt: b.String()}},
+			})
+			next = append(next, node{key, min(size, partialChars)})
+		}
+		level = next
+	}
+
+	return jobs
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HexmosTech/dPrompts/dprompts"
+)
+
+func TestChunkItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []string
+		maxChars int
+		want     [][]string
+	}{
+		{"empty", nil, 10, nil},
+		{"all fit", []string{"aa", "bb", "cc"}, 10, [][]string{{"aa", "bb", "cc"}}},
+		{"exact fit", []string{"aaaaa", "bbbbb", "c"}, 10, [][]string{{"aaaaa", "bbbbb"}, {"c"}}},
+		{"one per chunk", []string{"aaaa", "bbbb", "cccc"}, 5, [][]string{{"aaaa"}, {"bbbb"}, {"cccc"}}},
+		{"oversized item alone", []string{"a", "bbbbbbbbbbbb", "c"}, 10, [][]string{{"a"}, {"bbbbbbbbbbbb"}, {"c"}}},
+		{"oversized first", []string{"bbbbbbbbbbbb", "a", "c"}, 10, [][]string{{"bbbbbbbbbbbb"}, {"a", "c"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunkItems(tt.items, tt.maxChars); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunkItems(%q, %d) = %q, want %q", tt.items, tt.maxChars, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		sizes    []int
+		maxChars int
+		maxItems int
+		want     [][2]int
+	}{
+		{"by size", []int{4, 4, 4, 4}, 8, 0, [][2]int{{0, 2}, {2, 4}}},
+		{"by count", []int{1, 1, 1, 1, 1}, 100, 2, [][2]int{{0, 2}, {2, 4}, {4, 5}}},
+		{"size before count", []int{5, 5, 1, 1}, 10, 3, [][2]int{{0, 2}, {2, 4}}},
+		{"oversized", []int{20, 1}, 10, 0, [][2]int{{0, 1}, {1, 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := packRanges(tt.sizes, tt.maxChars, tt.maxItems); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("packRanges(%v, %d, %d) = %v, want %v", tt.sizes, tt.maxChars, tt.maxItems, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildReduceJobs(t *testing.T) {
+	chunk := func(n int) [][]string {
+		chunks := make([][]string, n)
+		for i := range chunks {
+			chunks[i] = []string{strings.Repeat("x", 100)}
+		}
+		return chunks
+	}
+
+	tests := []struct {
+		name         string
+		chunks       [][]string
+		maxChars     int
+		partialChars int
+		fanIn        int
+		wantKeys     []string
+		wantDeps     map[string][]string
+	}{
+		{
+			name:   "single chunk",
+			chunks: chunk(1), maxChars: 1000, partialChars: 100, fanIn: 8,
+			wantKeys: []string{"map-0"},
+		},
+		{
+			name:   "limited by fan-in",
+			chunks: chunk(5), maxChars: 1000, partialChars: 100, fanIn: 2,
+			wantKeys: []string{"map-0", "map-1", "map-2", "map-3", "map-4", "reduce-1-0", "reduce-1-1", "reduce-2-0", "reduce-3-0"},
+			wantDeps: map[string][]string{
+				"reduce-1-0": {"map-0", "map-1"},
+				"reduce-1-1": {"map-2", "map-3"},
+				"reduce-2-0": {"reduce-1-0", "reduce-1-1"},
+				"reduce-3-0": {"reduce-2-0", "map-4"},
+			},
+		},
+		{
+			name:   "leftover passed up",
+			chunks: chunk(3), maxChars: 1000, partialChars: 100, fanIn: 2,
+			wantKeys: []string{"map-0", "map-1", "map-2", "reduce-1-0", "reduce-2-0"},
+			wantDeps: map[string][]string{
+				"reduce-1-0": {"map-0", "map-1"},
+				"reduce-2-0": {"reduce-1-0", "map-2"},
+			},
+		},
+		{
+			name:   "limited by max chars",
+			chunks: chunk(4), maxChars: 250, partialChars: 100, fanIn: 8,
+			wantKeys: []string{"map-0", "map-1", "map-2", "map-3", "reduce-1-0", "reduce-1-1", "reduce-2-0"},
+			wantDeps: map[string][]string{
+				"reduce-1-0": {"map-0", "map-1"},
+				"reduce-1-1": {"map-2", "map-3"},
+				"reduce-2-0": {"reduce-1-0", "reduce-1-1"},
+			},
+		},
+		{
+			name:   "partials estimated below their input",
+			chunks: chunk(4), maxChars: 250, partialChars: 50, fanIn: 8,
+			wantKeys: []string{"map-0", "map-1", "map-2", "map-3", "reduce-1-0"},
+			wantDeps: map[string][]string{
+				"reduce-1-0": {"map-0", "map-1", "map-2", "map-3"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := buildReduceJobs(tt.chunks, "reduce", "merge", tt.maxChars, tt.partialChars, tt.fanIn, "m")
+
+			var keys []string
+			for _, job := range jobs {
+				keys = append(keys, job.Key)
+				if strings.HasPrefix(job.Key, "reduce-") && len(job.DependsOn) < 2 {
+					t.Errorf("%s merges only %v", job.Key, job.DependsOn)
+				}
+				if want, ok := tt.wantDeps[job.Key]; ok && !reflect.DeepEqual(job.DependsOn, want) {
+					t.Errorf("%s depends on %v, want %v", job.Key, job.DependsOn, want)
+				}
+				for _, dep := range job.DependsOn {
+					if !strings.Contains(job.SubTasks[0].Prompt, "{{job."+dep+"}}") {
+						t.Errorf("%s does not reference %s", job.Key, dep)
+					}
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestBuildReduceJobsKeepsItemsVerbatim(t *testing.T) {
+	items := []string{"use {{subtask_3}} here", `and {{ job.other.subtask_0 }} in {"a":{{1}}}`}
+	jobs := buildReduceJobs([][]string{items}, "reduce", "merge", 1000, 100, 8, "")
+
+	args := DPromptsJobArgs{SubTasks: jobs[0].SubTasks, Mode: JobModeIndependent}
+	if err := dprompts.ValidateJobArgs(args); err != nil {
+		t.Fatalf("ValidateJobArgs: %v", err)
+	}
+
+	prompt, err := renderSubtaskPrompt(jobs[0].SubTasks[0], nil, nil)
+	if err != nil {
+		t.Fatalf("renderSubtaskPrompt: %v", err)
+	}
+	for _, item := range items {
+		if !strings.Contains(prompt, item) {
+			t.Errorf("rendered prompt does not contain %q:\n%s", item, prompt)
+		}
+	}
+}
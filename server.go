@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/HexmosTech/dPrompts/dprompts"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/rs/zerolog/log"
+)
+
+const maxRequestBodyBytes = 32 << 20
+
+// JobStatus is a river_job row as returned by the API.
+type JobStatus struct {
+	ID          int64                    `json:"id"`
+	Kind        string                   `json:"kind"`
+	State       string                   `json:"state"`
+	Queue       string                   `json:"queue"`
+	Priority    int                      `json:"priority"`
+	Attempt     int                      `json:"attempt"`
+	MaxAttempts int                      `json:"max_attempts"`
+	GroupName   string                   `json:"group_name,omitempty"`
+	CreatedAt   time.Time                `json:"created_at"`
+	ScheduledAt time.Time                `json:"scheduled_at"`
+	AttemptedAt *time.Time               `json:"attempted_at,omitempty"`
+	FinalizedAt *time.Time               `json:"finalized_at,omitempty"`
+	Errors      []rivertype.AttemptError `json:"errors,omitempty"`
+	Args        json.RawMessage          `json:"args"`
+	Metadata    json.RawMessage          `json:"metadata,omitempty"`
+}
+
+func newJobStatus(job *rivertype.JobRow) JobStatus {
+	status := JobStatus{
+		ID:          job.ID,
+		Kind:        job.Kind,
+		State:       string(job.State),
+		Queue:       job.Queue,
+		Priority:    job.Priority,
+		Attempt:     job.Attempt,
+		MaxAttempts: job.MaxAttempts,
+		CreatedAt:   job.CreatedAt,
+		ScheduledAt: job.ScheduledAt,
+		AttemptedAt: job.AttemptedAt,
+		FinalizedAt: job.FinalizedAt,
+		Errors:      job.Errors,
+		Args:        job.EncodedArgs,
+		Metadata:    job.Metadata,
+	}
+
+	var args DPromptsJobArgs
+	if json.Unmarshal(job.EncodedArgs, &args) == nil {
+		status.GroupName = args.GroupName
+	}
+	return status
+}
+
+// apiResult is a stored result with JSON subtask outputs decoded, so API
+// clients do not have to parse strings inside the response.
+type apiResult struct {
+	ID        int                       `json:"id"`
+	JobID     int64                     `json:"job_id"`
+	GroupName string                    `json:"group_name,omitempty"`
+	Response  any                       `json:"response"`
+	Usage     map[string]SubtaskMetrics `json:"usage,omitempty"`
+	CreatedAt time.Time                 `json:"created_at"`
+}
+
+func newAPIResult(res dprompts.Result) apiResult {
+	response := make(map[string]any, len(res.Outputs))
+	for key, output := range res.Outputs {
+		response[key] = output
+	}
+	return apiResult{
+		ID:        res.ID,
+		JobID:     res.JobID,
+		GroupName: res.GroupName,
+		Response:  normalizeJSON(response),
+		Usage:     res.Usage,
+		CreatedAt: res.CreatedAt,
+	}
+}
+
+// apiServer serves the JSON API over one shared connection pool.
+type apiServer struct {
+	db     *pgxpool.Pool
+	client *dprompts.Client
+	token  string
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/jobs", s.handleEnqueue)
+	mux.HandleFunc("POST /api/jobs/bulk", s.handleEnqueueBulk)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("GET /api/jobs/{id}/result", s.handleGetResult)
+	mux.HandleFunc("GET /api/groups", s.handleListGroups)
+	mux.HandleFunc("GET /api/groups/{group}/results", s.handleGroupResults)
+	mux.HandleFunc("DELETE /api/groups/{group}", s.handleDeleteGroup)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.db.Ping(r.Context()); err != nil {
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return s.withAuth(s.withLogging(mux))
+}
+
+func (s *apiServer) withAuth(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *apiServer) withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Info().
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", rec.status).
+			Str("duration", humanizeDuration(time.Since(start))).
+			Msg("HTTP request")
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// enqueueResponse describes an inserted job.
+type enqueueResponse struct {
+	ID          int64     `json:"id"`
+	State       string    `json:"state"`
+	Queue       string    `json:"queue"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
+
+func newEnqueueResponse(job *rivertype.JobRow) enqueueResponse {
+	return enqueueResponse{
+		ID:          job.ID,
+		State:       string(job.State),
+		Queue:       job.Queue,
+		ScheduledAt: job.ScheduledAt,
+	}
+}
+
+// enqueueOptionsFromQuery reads the group, queue, priority, run_at and delay
+// query parameters, the API's counterpart of the `dpr client` flags.
+func enqueueOptionsFromQuery(r *http.Request) (dprompts.EnqueueOptions, error) {
+	q := r.URL.Query()
+	opts := dprompts.EnqueueOptions{
+		GroupName: q.Get("group"),
+		Queue:     q.Get("queue"),
+		RunAt:     q.Get("run_at"),
+		Delay:     q.Get("delay"),
+	}
+	if p := q.Get("priority"); p != "" {
+		priority, err := strconv.Atoi(p)
+		if err != nil {
+			return opts, fmt.Errorf("invalid priority %q", p)
+		}
+		opts.Priority = priority
+	}
+	return opts, nil
+}
+
+// handleEnqueue inserts one job. The body has the fields of a bulk file
+// entry; depends_on is not available for single jobs.
+func (s *apiServer) handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	opts, err := enqueueOptionsFromQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var job dprompts.Job
+	if err := decodeBody(w, r, &job); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	row, err := s.client.Enqueue(r.Context(), job, opts)
+	if err != nil {
+		writeError(w, enqueueErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newEnqueueResponse(row))
+}
+
+// handleEnqueueBulk inserts a JSON array of jobs, with the same format and
+// workflow support as `dpr client --bulk-from-file`, in one transaction.
+func (s *apiServer) handleEnqueueBulk(w http.ResponseWriter, r *http.Request) {
+	opts, err := enqueueOptionsFromQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var jobs []dprompts.Job
+	if err := decodeBody(w, r, &jobs); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rows, err := s.client.EnqueueBulk(r.Context(), jobs, opts)
+	if err != nil {
+		writeError(w, enqueueErrorStatus(err), err)
+		return
+	}
+
+	inserted := make([]enqueueResponse, 0, len(rows))
+	for _, row := range rows {
+		inserted = append(inserted, newEnqueueResponse(row))
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"inserted": len(inserted), "jobs": inserted})
+}
+
+func enqueueErrorStatus(err error) int {
+	if errors.Is(err, dprompts.ErrInvalidJob) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *apiServer) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid job ID %q", r.PathValue("id")))
+		return
+	}
+
+	job, err := s.client.GetJob(r.Context(), jobID)
+	if errors.Is(err, dprompts.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newJobStatus(job))
+}
+
+func (s *apiServer) handleGetResult(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid job ID %q", r.PathValue("id")))
+		return
+	}
+
+	res, err := s.client.GetResult(r.Context(), jobID)
+	if errors.Is(err, dprompts.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIResult(*res))
+}
+
+func (s *apiServer) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := s.client.ListGroups(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// handleGroupResults pages through a group's results with ?limit= (default
+// 100, at most 1000) and ?offset=.
+func (s *apiServer) handleGroupResults(w http.ResponseWriter, r *http.Request) {
+	groupID, name, ok := s.resolveGroup(w, r)
+	if !ok {
+		return
+	}
+
+	limit, err := queryInt(r, "limit", 100)
+	if err != nil || limit < 1 || limit > 1000 {
+		writeError(w, http.StatusBadRequest, errors.New("limit must be between 1 and 1000"))
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("offset must not be negative"))
+		return
+	}
+
+	results, err := s.client.GroupResults(r.Context(), name, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	page := make([]apiResult, 0, len(results))
+	for _, res := range results {
+		page = append(page, newAPIResult(res))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"group_id": groupID, "group_name": name, "results": page})
+}
+
+func (s *apiServer) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	groupID, name, ok := s.resolveGroup(w, r)
+	if !ok {
+		return
+	}
+
+	results, groups, err := deleteGroup(r.Context(), s.db, groupID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Info().Int("group_id", groupID).Str("group_name", name).Int64("results", results).Msg("Deleted group via API")
+	writeJSON(w, http.StatusOK, map[string]any{"group_id": groupID, "group_name": name, "deleted_results": results, "deleted_groups": groups})
+}
+
+// resolveGroup looks up the {group} path value by name or ID and writes
+// the error response when it cannot.
+func (s *apiServer) resolveGroup(w http.ResponseWriter, r *http.Request) (int, string, bool) {
+	groupID, name, err := resolveGroupRef(r.Context(), s.db, r.PathValue("group"))
+	if errors.Is(err, errGroupNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return 0, "", false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return 0, "", false
+	}
+	return groupID, name, true
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("Failed to write response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		log.Error().Err(err).Msg("API request failed")
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// RunServer serves the API until SIGINT or SIGTERM.
+func RunServer(ctx context.Context, db *pgxpool.Pool, client *dprompts.Client, conf *ServerConfig) error {
+	api := &apiServer{db: db, client: client, token: conf.Token}
+	srv := &http.Server{
+		Addr:              conf.Addr,
+		Handler:           api.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	if conf.Token == "" && !strings.HasPrefix(conf.Addr, "127.0.0.1:") && !strings.HasPrefix(conf.Addr, "localhost:") {
+		log.Warn().Str("addr", conf.Addr).Msg("Serving without a token on a non-loopback address")
+	}
+	log.Info().Str("addr", conf.Addr).Msg("API server started. Press Ctrl+C to exit.")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-stop:
+	}
+
+	log.Info().Msg("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
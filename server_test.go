@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/HexmosTech/dPrompts/dprompts"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDatabaseURLEnv names a migrated database for the tests that need
+// one; they are skipped when it is not set.
+const testDatabaseURLEnv = "DPROMPTS_TEST_DATABASE_URL"
+
+// newTestAPI returns the API's handler over dbURL. The pool connects
+// lazily, so requests rejected before any query work with an unreachable
+// database.
+func newTestAPI(t *testing.T, dbURL, token string) http.Handler {
+	t.Helper()
+	db, err := pgxpool.New(context.Background(), dbURL)
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	client, err := dprompts.NewClient(db)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return (&apiServer{db: db, client: client, token: token}).routes()
+}
+
+type apiTest struct {
+	name       string
+	method     string
+	path       string
+	body       string
+	auth       string
+	wantStatus int
+	wantError  string // part of the error message; empty to skip the check
+}
+
+func runAPITests(t *testing.T, handler http.Handler, tests []apiTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding error response: %v", err)
+			}
+			if !strings.Contains(body.Error, tt.wantError) {
+				t.Errorf("error = %q, want it to contain %q", body.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestAPIRejectsRequests(t *testing.T) {
+	handler := newTestAPI(t, "postgres://dpr@127.0.0.1:1/dprompts?connect_timeout=1", "secret")
+	const auth = "Bearer secret"
+
+	runAPITests(t, handler, []apiTest{
+		{name: "no token", method: "GET", path: "/api/groups", wantStatus: http.StatusUnauthorized, wantError: "bearer token"},
+		{name: "wrong token", method: "GET", path: "/api/jobs/1", auth: "Bearer nope", wantStatus: http.StatusUnauthorized, wantError: "bearer token"},
+		{name: "token without scheme", method: "GET", path: "/api/jobs/1", auth: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "bad JSON", method: "POST", path: "/api/jobs", body: `{"sub_tasks": [`, auth: auth, wantStatus: http.StatusBadRequest, wantError: "invalid JSON body"},
+		{name: "missing sub_tasks", method: "POST", path: "/api/jobs", body: `{"base_prompt": "x"}`, auth: auth, wantStatus: http.StatusBadRequest, wantError: "sub_task"},
+		{name: "depends_on in a single job", method: "POST", path: "/api/jobs", body: `{"depends_on": ["a"], "sub_tasks": [{"prompt": "x"}]}`, auth: auth, wantStatus: http.StatusBadRequest, wantError: "depends_on"},
+		{name: "invalid priority", method: "POST", path: "/api/jobs?priority=high", body: `{"sub_tasks": [{"prompt": "x"}]}`, auth: auth, wantStatus: http.StatusBadRequest, wantError: "invalid priority"},
+		{name: "empty bulk", method: "POST", path: "/api/jobs/bulk", body: `[]`, auth: auth, wantStatus: http.StatusBadRequest, wantError: "no jobs"},
+		{name: "bulk not an array", method: "POST", path: "/api/jobs/bulk", body: `{"sub_tasks": []}`, auth: auth, wantStatus: http.StatusBadRequest, wantError: "invalid JSON body"},
+		{name: "invalid job ID", method: "GET", path: "/api/jobs/abc", auth: auth, wantStatus: http.StatusBadRequest, wantError: "invalid job ID"},
+		{name: "GET on enqueue", method: "GET", path: "/api/jobs", auth: auth, wantStatus: http.StatusMethodNotAllowed},
+		{name: "POST on a job", method: "POST", path: "/api/jobs/1", auth: auth, wantStatus: http.StatusMethodNotAllowed},
+		{name: "PUT on a group", method: "PUT", path: "/api/groups/g", auth: auth, wantStatus: http.StatusMethodNotAllowed},
+		{name: "unknown route", method: "GET", path: "/api/nope", auth: auth, wantStatus: http.StatusNotFound},
+	})
+}
+
+func TestAPINotFound(t *testing.T) {
+	dbURL := os.Getenv(testDatabaseURLEnv)
+	if dbURL == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+	handler := newTestAPI(t, dbURL, "")
+
+	runAPITests(t, handler, []apiTest{
+		{name: "unknown job", method: "GET", path: "/api/jobs/9223372036854775807", wantStatus: http.StatusNotFound, wantError: "not found"},
+		{name: "result of unknown job", method: "GET", path: "/api/jobs/9223372036854775807/result", wantStatus: http.StatusNotFound, wantError: "not found"},
+		{name: "results of unknown group", method: "GET", path: "/api/groups/dprompts-test-no-such-group/results", wantStatus: http.StatusNotFound, wantError: "not found"},
+		{name: "delete unknown group", method: "DELETE", path: "/api/groups/dprompts-test-no-such-group", wantStatus: http.StatusNotFound, wantError: "not found"},
+	})
+}
@@ -1,5 +1,7 @@
 package main
 
+import "github.com/HexmosTech/dPrompts/dprompts"
+
 type DBConfig struct {
 	Engine   string
 	Name     string
@@ -9,40 +11,81 @@ type DBConfig struct {
 	Port     string
 }
 
-type DPromptsSubTask struct {
-	Prompt   string                 `json:"prompt"`
-	Schema   interface{}            `json:"schema,omitempty"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"` // <-- new
-
-}
+// The job format and result types are defined by the dprompts package.
+type (
+	DPromptsSubTask = dprompts.SubTask
+	DPromptsJobArgs = dprompts.JobArgs
+	LLMOptions      = dprompts.LLMOptions
+	LLMUsage        = dprompts.LLMUsage
+	SubtaskMetrics  = dprompts.SubtaskMetrics
+)
 
-type DPromptsJobArgs struct {
-	SubTasks   []DPromptsSubTask `json:"sub_tasks"`
-	BasePrompt string            `json:"base_prompt,omitempty"`
-}
+const (
+	JobModeIndependent  = dprompts.JobModeIndependent
+	JobModeConversation = dprompts.JobModeConversation
+)
 
 type DPromptsJobResult struct {
 	Response string `json:"response"`
 }
 
-func (DPromptsJobArgs) Kind() string {
-	return "dprompts-worker"
-}
-
 type LLMConfig struct {
+	Provider    string  `toml:"provider"` // ollama (default) | openai | mock
 	APIEndpoint string  `toml:"api-endpoint"`
+	APIKey      string  `toml:"api-key"` // optional, sent as a Bearer token by the openai provider
 	Model       string  `toml:"model"`
 	Temperature float64 `toml:"temperature"`
 	TopP        float64 `toml:"topP"`
+
+	// SchemaRepairAttempts is how many times invalid structured output is
+	// sent back to the model with the validation error before giving up.
+	SchemaRepairAttempts int `toml:"schema_repair_attempts"`
+
+	Mock   MockConfig   `toml:"mock"`
+	OpenAI OpenAIConfig `toml:"openai"`
+}
+
+// OpenAIConfig configures the openai provider ([llm.openai]).
+type OpenAIConfig struct {
+	// Strict forces "strict" on or off for subtask schemas. When unset it is
+	// only sent for schemas that follow the strict mode rules.
+	Strict *bool `toml:"strict"`
+	// JSONObject asks for {"type":"json_object"} when a subtask has no
+	// schema. Not every server supports it, so it is off by default.
+	JSONObject bool `toml:"json_object"`
+}
+
+// MockConfig configures the offline mock provider ([llm.mock]).
+type MockConfig struct {
+	Response      string  `toml:"response"`       // canned response; generated from the schema when empty
+	Latency       string  `toml:"latency"`        // e.g. "200ms"
+	LatencyJitter string  `toml:"latency_jitter"` // extra random latency up to this duration
+	FailureRate   float64 `toml:"failure_rate"`   // 0..1
+	Seed          int64   `toml:"seed"`
 }
 
 type OllamaResponse struct {
+	Model   string `json:"model"`
 	Message struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
 	} `json:"message"`
+	Done bool `json:"done"`
+
+	// Only set on the final chunk (done = true).
+	TotalDuration   int64 `json:"total_duration"`
+	LoadDuration    int64 `json:"load_duration"`
+	PromptEvalCount int   `json:"prompt_eval_count"`
+	EvalCount       int   `json:"eval_count"`
 }
 
 type WorkerConfig struct {
-	ConcurrentWorkers int `toml:"concurrent_workers"`
+	ConcurrentWorkers     int            `toml:"concurrent_workers"`
+	MaxSubtaskParallelism int            `toml:"max_subtask_parallelism"`
+	Queues                map[string]int `toml:"queues"` // queue name -> MaxWorkers
+}
+
+type ServerConfig struct {
+	Addr  string `toml:"addr"`
+	Token string `toml:"token"` // when set, requests need "Authorization: Bearer <token>"
 }
@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -11,7 +14,7 @@ import (
 
 func viewLastResults(ctx context.Context, db *pgxpool.Pool, n int) error {
 	rows, err := db.Query(ctx, `
-        SELECT r.id, r.job_id, r.response, r.created_at, g.group_name
+        SELECT r.id, r.job_id, r.response, r.usage, r.created_at, g.group_name
         FROM dprompts_results r
         LEFT JOIN dprompt_groups g ON r.group_id = g.id
         ORDER BY r.created_at DESC
@@ -26,10 +29,11 @@ func viewLastResults(ctx context.Context, db *pgxpool.Pool, n int) error {
 		var id int
 		var jobID int64
 		var responseData []byte
+		var usageData []byte
 		var createdAt time.Time
 		var groupName *string
 
-		if err := rows.Scan(&id, &jobID, &responseData, &createdAt, &groupName); err != nil {
+		if err := rows.Scan(&id, &jobID, &responseData, &usageData, &createdAt, &groupName); err != nil {
 			return err
 		}
 
@@ -41,8 +45,8 @@ func viewLastResults(ctx context.Context, db *pgxpool.Pool, n int) error {
 		// Step 1: Unmarshal outer JSON
 		var outer map[string]string
 		if err := json.Unmarshal(responseData, &outer); err != nil {
-			fmt.Printf("ID: %d | JobID: %d | Group: %s | CreatedAt: %s\nResponse: %s\n\n",
-				id, jobID, gn, createdAt.Format(time.RFC3339), string(responseData))
+			fmt.Printf("ID: %d | JobID: %d | Group: %s | CreatedAt: %s\nResponse: %s\n%s\n",
+				id, jobID, gn, createdAt.Format(time.RFC3339), string(responseData), formatUsage(usageData))
 			continue
 		}
 
@@ -50,16 +54,16 @@ func viewLastResults(ctx context.Context, db *pgxpool.Pool, n int) error {
 		var inner any
 		if err := json.Unmarshal([]byte(outer["response"]), &inner); err != nil {
 			// fallback: just print inner string raw
-			fmt.Printf("ID: %d | JobID: %d | Group: %s | CreatedAt: %s\nResponse: %s\n\n",
-				id, jobID, gn, createdAt.Format(time.RFC3339), outer["response"])
+			fmt.Printf("ID: %d | JobID: %d | Group: %s | CreatedAt: %s\nResponse: %s\n%s\n",
+				id, jobID, gn, createdAt.Format(time.RFC3339), outer["response"], formatUsage(usageData))
 			continue
 		}
 
 		// Step 3: Pretty-print the inner JSON
 		prettyInner, _ := json.MarshalIndent(inner, "", "  ")
 
-		fmt.Printf("ID: %d | JobID: %d | Group: %s | CreatedAt: %s\nResponse:\n%s\n\n",
-			id, jobID, gn, createdAt.Format(time.RFC3339), string(prettyInner))
+		fmt.Printf("ID: %d | JobID: %d | Group: %s | CreatedAt: %s\nResponse:\n%s\n%s\n",
+			id, jobID, gn, createdAt.Format(time.RFC3339), string(prettyInner), formatUsage(usageData))
 	}
 
 	return rows.Err()
@@ -150,7 +154,7 @@ func viewTotalGroups(ctx context.Context, db *pgxpool.Pool) error {
 // CLI: Display results filtered by group ID
 func viewResultsByGroup(ctx context.Context, db *pgxpool.Pool, groupID int) error {
 	rows, err := db.Query(ctx, `
-        SELECT r.id, r.job_id, r.response, r.created_at, g.group_name
+        SELECT r.id, r.job_id, r.response, r.usage, r.created_at, g.group_name
         FROM dprompts_results r
         JOIN dprompt_groups g ON r.group_id = g.id
         WHERE g.id = $1
@@ -166,11 +170,12 @@ func viewResultsByGroup(ctx context.Context, db *pgxpool.Pool, groupID int) erro
 			id          int
 			jobID       int64
 			responseRaw []byte
+			usageRaw    []byte
 			createdAt   time.Time
 			groupName   string
 		)
 
-		if err := rows.Scan(&id, &jobID, &responseRaw, &createdAt, &groupName); err != nil {
+		if err := rows.Scan(&id, &jobID, &responseRaw, &usageRaw, &createdAt, &groupName); err != nil {
 			return err
 		}
 
@@ -186,7 +191,7 @@ func viewResultsByGroup(ctx context.Context, db *pgxpool.Pool, groupID int) erro
 		var data any
 		if err := json.Unmarshal(responseRaw, &data); err != nil {
 			// Not JSON at all → print raw string
-			fmt.Printf("Response:\n%s\n\n", string(responseRaw))
+			fmt.Printf("Response:\n%s\n%s\n", string(responseRaw), formatUsage(usageRaw))
 			continue
 		}
 
@@ -194,12 +199,69 @@ func viewResultsByGroup(ctx context.Context, db *pgxpool.Pool, groupID int) erro
 
 		pretty, err := json.MarshalIndent(normalized, "", "  ")
 		if err != nil {
-			fmt.Printf("Response:\n%v\n\n", normalized)
+			fmt.Printf("Response:\n%v\n%s\n", normalized, formatUsage(usageRaw))
 			continue
 		}
 
-		fmt.Printf("Response:\n%s\n\n", pretty)
+		fmt.Printf("Response:\n%s\n%s\n", pretty, formatUsage(usageRaw))
 	}
 
 	return rows.Err()
 }
+
+// formatUsage renders the per-subtask usage stored with a result, one line
+// per subtask plus a total. Results stored without usage print nothing.
+func formatUsage(raw []byte) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var metrics map[string]SubtaskMetrics
+	if err := json.Unmarshal(raw, &metrics); err != nil || len(metrics) == 0 {
+		return ""
+	}
+
+	var (
+		b     strings.Builder
+		total LLMUsage
+		wall  time.Duration
+	)
+
+	b.WriteString("Usage:\n")
+	for _, key := range sortedSubtaskKeys(metrics) {
+		m := metrics[key]
+		total.Add(m.LLMUsage)
+		wall += m.WallTime()
+
+		fmt.Fprintf(&b, "  %s | Provider: %s | Model: %s | Tokens: %d in / %d out | Attempts: %d | Time: %s",
+			key, m.Provider, m.Model, m.PromptEvalCount, m.EvalCount, m.Attempts, humanizeDuration(m.WallTime()))
+		if m.LoadDuration > 0 {
+			fmt.Fprintf(&b, " | Load: %s", humanizeDuration(time.Duration(m.LoadDuration)))
+		}
+		if opts, err := json.Marshal(m.Options); err == nil && string(opts) != "{}" {
+			fmt.Fprintf(&b, " | Options: %s", opts)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "  total | Tokens: %d in / %d out | Time: %s\n",
+		total.PromptEvalCount, total.EvalCount, humanizeDuration(wall))
+
+	return b.String()
+}
+
+// sortedSubtaskKeys orders subtask_N keys numerically.
+func sortedSubtaskKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.Atoi(strings.TrimPrefix(keys[i], "subtask_"))
+		b, errB := strconv.Atoi(strings.TrimPrefix(keys[j], "subtask_"))
+		if errA != nil || errB != nil {
+			return keys[i] < keys[j]
+		}
+		return a < b
+	})
+	return keys
+}
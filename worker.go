@@ -7,19 +7,31 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/HexmosTech/dPrompts/dprompts"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/riverqueue/river"
 	"github.com/riverqueue/river/riverdriver/riverpgxv5"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
 )
 
 type DPromptsWorker struct {
 	river.WorkerDefaults[DPromptsJobArgs]
-	db *pgxpool.Pool // Database pool
+	db        *pgxpool.Pool // Database pool
+	provider  Provider      // LLM backend
+	llmConfig *LLMConfig
+
+	maxSubtaskParallelism int // cap on concurrent subtasks within one job
+
+	// subtaskSlots holds max_subtask_parallelism slots shared by every job
+	// running subtasks in parallel, so concurrent jobs cannot multiply the
+	// load on the backend.
+	subtaskSlots chan struct{}
 }
 
 func (w *DPromptsWorker) Timeout(job *river.Job[DPromptsJobArgs]) time.Duration {
@@ -44,8 +56,10 @@ func (w *DPromptsWorker) Work(ctx context.Context, job *river.Job[DPromptsJobArg
 	jobStart := time.Now()
 	jobID := strconv.FormatInt(job.ID, 10)
 
-	var groupName string
-	if len(job.Metadata) > 0 {
+	// Jobs enqueued before group_name was part of the args carry it in
+	// their metadata.
+	groupName := job.Args.GroupName
+	if groupName == "" && len(job.Metadata) > 0 {
 		var meta map[string]any
 		if err := json.Unmarshal(job.Metadata, &meta); err == nil {
 			if v, ok := meta["group_name"].(string); ok {
@@ -58,74 +72,71 @@ func (w *DPromptsWorker) Work(ctx context.Context, job *river.Job[DPromptsJobArg
 		Str("job_id", jobID).
 		Msg("Job started")
 
-	homeDir, err := os.UserHomeDir()
+	// Subtasks finished by earlier attempts of this job are not re-run.
+	results, metrics, err := w.loadSubtaskResults(ctx, job.ID)
 	if err != nil {
-		log.Error().Err(err).Msg("Unable to determine home directory for config file")
 		return err
 	}
-	configPath := homeDir + string(os.PathSeparator) + ".dprompts.toml"
-	tx, err := w.db.Begin(ctx)
-	if err != nil {
-		return err
+	if len(results) > 0 {
+		log.Info().
+			Str("job_id", jobID).
+			Int("completed_subtasks", len(results)).
+			Msg("Resuming job from stored subtask progress")
 	}
-	defer tx.Rollback(ctx)
 
-	groupID, err := w.resolveGroup(ctx, tx, jobID, groupName)
-	if err != nil {
+	// Jobs of a workflow see the results of the jobs they depend on.
+	var upstream map[string]map[string]string
+	if len(job.Args.DependsOn) > 0 {
+		if upstream, err = loadUpstreamResults(ctx, w.db, job.ID); err != nil {
+			return err
+		}
+	}
+	if job.Args, err = renderUpstreamResults(job.Args, upstream); err != nil {
 		return err
 	}
 
-	results := make(map[string]string)
-
-	var ollamaTotal time.Duration
+	var llmTotal time.Duration
 	var dbTotal time.Duration
 
 	// ---- subtasks ----
-	for i, sub := range job.Args.SubTasks {
-		log.Info().
-			Str("job_id", jobID).
-			Int("subtask", i).
-			Any("metadata", sub.Metadata).
-			Msg("Subtask started")
-		ollamaStart := time.Now()
-
-		response, err := CallOllama(
-			sub.Prompt,
-			sub.Schema,
-			configPath,
-			job.Args.BasePrompt,
-		)
-
-		ollamaDur := time.Since(ollamaStart)
-		ollamaTotal += ollamaDur
-
-		if err != nil {
-			log.Error().
-				Err(err).
-				Str("job_id", jobID).
-				Int("subtask", i).
-				Msg("Subtask failed")
-			return err
-		}
-
-		results[fmt.Sprintf("subtask_%d", i)] = response
-
-		log.Info().
-			Str("job_id", jobID).
-			Int("subtask", i).
-			Str("time_taken_by_ollama", humanizeDuration(ollamaDur)).
-			Msg("Subtask completed")
+	if job.Args.Mode == JobModeConversation {
+		llmTotal, err = w.runConversation(ctx, job, upstream, results, metrics)
+	} else {
+		llmTotal, err = w.runSubtaskGraph(ctx, job, upstream, results, metrics)
+	}
+	if err != nil {
+		return err
 	}
 
 	// ---- DB work ----
 	dbStart := time.Now()
 
+	tx, err := w.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	groupID, err := w.resolveGroup(ctx, tx, jobID, groupName)
+	if err != nil {
+		return err
+	}
+
 	jsonResponse, err := json.Marshal(results)
 	if err != nil {
 		return err
 	}
 
-	if err := w.insertResult(ctx, tx, job.ID, jsonResponse, groupID); err != nil {
+	jsonUsage, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+
+	if err := w.insertResult(ctx, tx, job.ID, jsonResponse, jsonUsage, groupID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM dprompts_subtask_results WHERE job_id = $1`, job.ID); err != nil {
 		return err
 	}
 
@@ -133,6 +144,16 @@ func (w *DPromptsWorker) Work(ctx context.Context, job *river.Job[DPromptsJobArg
 		return err
 	}
 
+	// After completing, so this job's row stays locked until commit; see
+	// the dprompts package's workflow.recordInserted.
+	released, err := dprompts.ReleaseDependents(ctx, tx, river.ClientFromContext[pgx.Tx](ctx), job.ID)
+	if err != nil {
+		return err
+	}
+	for _, id := range released {
+		log.Info().Int64("job_id", id).Int64("upstream_job_id", job.ID).Msg("Released dependent job")
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return err
 	}
@@ -145,7 +166,7 @@ func (w *DPromptsWorker) Work(ctx context.Context, job *river.Job[DPromptsJobArg
 	log.Info().
 		Str("job_id", jobID).
 		Int("subtasks", len(job.Args.SubTasks)).
-		Str("ollama_total", humanizeDuration(ollamaTotal)).
+		Str("llm_total", humanizeDuration(llmTotal)).
 		Str("db_total", humanizeDuration(dbTotal)).
 		Str("total_time", humanizeDuration(totalTime)).
 		Msg("Job completed")
@@ -153,44 +174,288 @@ func (w *DPromptsWorker) Work(ctx context.Context, job *river.Job[DPromptsJobArg
 	return nil
 }
 
-func RegisterWorkers(db *pgxpool.Pool) *river.Workers {
+// runSubtaskGraph runs the subtasks of a job as independent calls, in
+// parallel where allowed, starting each one once its dependencies are done.
+// Outputs are added to results and metrics; the summed LLM time is returned.
+func (w *DPromptsWorker) runSubtaskGraph(ctx context.Context, job *river.Job[DPromptsJobArgs], upstream map[string]map[string]string, results map[string]string, metrics map[string]SubtaskMetrics) (time.Duration, error) {
+	jobID := strconv.FormatInt(job.ID, 10)
+	var llmTotal time.Duration
+
+	parallelism := w.subtaskParallelism(job.Args)
+	if parallelism > 1 {
+		log.Info().
+			Str("job_id", jobID).
+			Int("parallelism", parallelism).
+			Msg("Running subtasks in parallel")
+	}
+
+	// Each subtask waits for the subtasks it depends on before taking one of
+	// the job's parallelism slots, so waiting never blocks a runnable
+	// subtask. Parallel jobs then also take a worker-wide slot; sequential
+	// jobs are already limited by the queue's worker count.
+	done := make([]chan struct{}, len(job.Args.SubTasks))
+	resumed := make([]bool, len(job.Args.SubTasks))
+	deps := make([][]int, len(job.Args.SubTasks))
+	for i := range done {
+		d, err := dprompts.SubtaskDependencies(job.Args.SubTasks, i)
+		if err != nil {
+			return 0, err
+		}
+		deps[i] = d
+
+		done[i] = make(chan struct{})
+		if _, ok := results[fmt.Sprintf("subtask_%d", i)]; ok {
+			resumed[i] = true
+			close(done[i])
+		}
+	}
+
+	var mu sync.Mutex
+	slots := make(chan struct{}, parallelism)
+	var shared chan struct{}
+	if parallelism > 1 {
+		shared = w.subtaskSlots
+	}
+	g, gctx := errgroup.WithContext(ctx)
+
+	for i, sub := range job.Args.SubTasks {
+		if resumed[i] {
+			continue
+		}
+		key := fmt.Sprintf("subtask_%d", i)
+
+		g.Go(func() error {
+			for _, dep := range deps[i] {
+				select {
+				case <-done[dep]:
+				case <-gctx.Done():
+					return gctx.Err()
+				}
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+			defer func() { <-slots }()
+
+			if shared != nil {
+				select {
+				case shared <- struct{}{}:
+				case <-gctx.Done():
+					return gctx.Err()
+				}
+				defer func() { <-shared }()
+			}
+
+			outputs := make(map[int]string, len(deps[i]))
+			mu.Lock()
+			for _, dep := range deps[i] {
+				outputs[dep] = results[fmt.Sprintf("subtask_%d", dep)]
+			}
+			mu.Unlock()
+
+			prompt, err := renderSubtaskPrompt(sub, outputs, upstream)
+			if err != nil {
+				return fmt.Errorf("sub_task[%d]: %w", i, err)
+			}
+			sub.Prompt = prompt
+
+			response, m, err := w.runSubtask(gctx, job, i, sub, nil)
+
+			mu.Lock()
+			llmTotal += m.WallTime()
+			if err == nil {
+				results[key] = response
+				metrics[key] = m
+			}
+			mu.Unlock()
+
+			if err != nil {
+				return err
+			}
+			close(done[i])
+			return nil
+		})
+	}
+
+	err := g.Wait()
+	return llmTotal, err
+}
+
+// runConversation runs the subtasks in order as one growing chat: every
+// earlier prompt and reply is sent along with the next prompt. Resumed
+// subtasks are replayed into the history from their stored outputs.
+func (w *DPromptsWorker) runConversation(ctx context.Context, job *river.Job[DPromptsJobArgs], upstream map[string]map[string]string, results map[string]string, metrics map[string]SubtaskMetrics) (time.Duration, error) {
+	var llmTotal time.Duration
+	var history []ChatMessage
+
+	outputs := make(map[int]string, len(job.Args.SubTasks))
+	for i, sub := range job.Args.SubTasks {
+		key := fmt.Sprintf("subtask_%d", i)
+
+		prompt, err := renderSubtaskPrompt(sub, outputs, upstream)
+		if err != nil {
+			return llmTotal, fmt.Errorf("sub_task[%d]: %w", i, err)
+		}
+		sub.Prompt = prompt
+
+		response, ok := results[key]
+		if !ok {
+			var m SubtaskMetrics
+			response, m, err = w.runSubtask(ctx, job, i, sub, history)
+			llmTotal += m.WallTime()
+			if err != nil {
+				return llmTotal, err
+			}
+			results[key] = response
+			metrics[key] = m
+		}
+
+		outputs[i] = response
+		history = append(history,
+			ChatMessage{Role: "user", Content: sub.Prompt},
+			ChatMessage{Role: "assistant", Content: response},
+		)
+	}
+
+	return llmTotal, nil
+}
+
+// runSubtask calls the LLM for one subtask and stores its output as progress.
+// history holds earlier conversation turns (nil outside conversation mode).
+func (w *DPromptsWorker) runSubtask(ctx context.Context, job *river.Job[DPromptsJobArgs], i int, sub DPromptsSubTask, history []ChatMessage) (string, SubtaskMetrics, error) {
+	jobID := strconv.FormatInt(job.ID, 10)
+
+	log.Info().
+		Str("job_id", jobID).
+		Int("subtask", i).
+		Any("metadata", sub.Metadata).
+		Msg("Subtask started")
+	llmStart := time.Now()
+
+	callResult, err := CallLLM(ctx, w.provider, job.Args, sub, history, w.llmConfig.SchemaRepairAttempts)
+
+	llmDur := time.Since(llmStart)
+
+	m := SubtaskMetrics{
+		Provider:   w.provider.Name(),
+		Model:      callResult.Model,
+		Options:    callResult.Options,
+		LLMUsage:   callResult.Usage,
+		Attempts:   callResult.Calls,
+		WallTimeMs: llmDur.Milliseconds(),
+	}
+
+	if len(callResult.Attempts) > 0 {
+		w.recordSchemaAttempts(ctx, job.ID, i, callResult.Attempts)
+	}
+
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("job_id", jobID).
+			Int("subtask", i).
+			Msg("Subtask failed")
+		return "", m, err
+	}
+
+	if err := w.saveSubtaskResult(ctx, job.ID, i, callResult.Content, m); err != nil {
+		return "", m, err
+	}
+
+	log.Info().
+		Str("job_id", jobID).
+		Int("subtask", i).
+		Str("provider", w.provider.Name()).
+		Int("schema_attempts", len(callResult.Attempts)).
+		Str("model", m.Model).
+		Int("prompt_tokens", m.PromptEvalCount).
+		Int("output_tokens", m.EvalCount).
+		Str("time_taken_by_llm", humanizeDuration(llmDur)).
+		Msg("Subtask completed")
+
+	return callResult.Content, m, nil
+}
+
+// subtaskParallelism is the job's requested parallelism capped by
+// max_subtask_parallelism. Jobs run sequentially by default.
+func (w *DPromptsWorker) subtaskParallelism(args DPromptsJobArgs) int {
+	p := args.Parallelism
+	if p < 1 {
+		p = 1
+	}
+	if p > w.maxSubtaskParallelism {
+		p = w.maxSubtaskParallelism
+	}
+	return p
+}
+
+func RegisterWorkers(db *pgxpool.Pool, provider Provider, llmConfig *LLMConfig, workerConfig *WorkerConfig) *river.Workers {
 	workers := river.NewWorkers()
-	river.AddWorker(workers, &DPromptsWorker{db: db})
+	river.AddWorker(workers, &DPromptsWorker{
+		db:                    db,
+		provider:              provider,
+		llmConfig:             llmConfig,
+		maxSubtaskParallelism: workerConfig.MaxSubtaskParallelism,
+		subtaskSlots:          make(chan struct{}, workerConfig.MaxSubtaskParallelism),
+	})
+	river.AddWorker(workers, &DPromptsPeriodicWorker{db: db})
 	return workers
 }
 
+// createWorkerClient works the default queue with concurrent_workers and
+// every queue of [worker.queues] with its own worker count. Listing
+// "default" there overrides concurrent_workers.
 func createWorkerClient(
 	driver *riverpgxv5.Driver,
 	workers *river.Workers,
-	concurrentWorkers int) (*river.Client[pgx.Tx], error) {
-	log.Info().
-		Int("concurrent_workers", concurrentWorkers).
-		Msg("Initializing River worker client")
+	workerConfig *WorkerConfig,
+	periodic []PeriodicConfig) (*river.Client[pgx.Tx], error) {
+	queues := map[string]river.QueueConfig{
+		river.QueueDefault: {MaxWorkers: workerConfig.ConcurrentWorkers},
+	}
+	for name, maxWorkers := range workerConfig.Queues {
+		queues[name] = river.QueueConfig{MaxWorkers: maxWorkers}
+	}
+
+	for name, q := range queues {
+		log.Info().
+			Str("queue", name).
+			Int("max_workers", q.MaxWorkers).
+			Msg("Initializing River worker queue")
+	}
+	for _, p := range periodic {
+		log.Info().
+			Str("schedule", p.Name).
+			Str("cron", p.Cron).
+			Msg("Registering periodic schedule")
+	}
 	return river.NewClient[pgx.Tx](driver, &river.Config{
-		Queues: map[string]river.QueueConfig{
-			river.QueueDefault: {MaxWorkers: concurrentWorkers},
-		},
+		Queues:                      queues,
 		Workers:                     workers,
+		PeriodicJobs:                periodicJobs(periodic),
 		CompletedJobRetentionPeriod: 72 * time.Hour,
 	})
 }
 
-func RunWorker(ctx context.Context, driver *riverpgxv5.Driver, cancel context.CancelFunc, db *pgxpool.Pool) {
-
-	homeDir, err := os.UserHomeDir()
+func RunWorker(ctx context.Context, driver *riverpgxv5.Driver, cancel context.CancelFunc, db *pgxpool.Pool, configPath string, provider Provider, llmConfig *LLMConfig) {
+	workerConfig, err := LoadWorkerConfig(configPath)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Unable to determine home directory")
+		log.Fatal().Err(err).Msg("Failed to load worker config")
 	}
 
-	configPath := homeDir + string(os.PathSeparator) + ".dprompts.toml"
-
-	workerConfig, err := LoadWorkerConfig(configPath)
+	periodic, err := LoadPeriodicConfig(configPath)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to load worker config")
+		log.Fatal().Err(err).Msg("Failed to load periodic schedules")
+	}
+	if err := storePeriodicSources(ctx, db, periodic); err != nil {
+		log.Fatal().Err(err).Msg("Failed to store periodic bulk files")
 	}
 
-	workers := RegisterWorkers(db)
-	riverClient, err := createWorkerClient(driver, workers, workerConfig.ConcurrentWorkers)
+	workers := RegisterWorkers(db, provider, llmConfig, workerConfig)
+	riverClient, err := createWorkerClient(driver, workers, workerConfig, periodic)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create River client")
 	}
@@ -250,19 +515,124 @@ func (w *DPromptsWorker) resolveGroup(ctx context.Context, tx pgx.Tx, jobID stri
 }
 
 // insertResult inserts or updates a dprompt result for a job
-func (w *DPromptsWorker) insertResult(ctx context.Context, tx pgx.Tx, jobID int64, jsonResponse []byte, groupID *int) error {
+func (w *DPromptsWorker) insertResult(ctx context.Context, tx pgx.Tx, jobID int64, jsonResponse []byte, jsonUsage []byte, groupID *int) error {
 	_, err := tx.Exec(ctx,
-		`INSERT INTO dprompts_results (job_id, response, group_id)
-		 VALUES ($1, $2, $3)
+		`INSERT INTO dprompts_results (job_id, response, usage, group_id)
+		 VALUES ($1, $2, $3, $4)
 		 ON CONFLICT (job_id)
 		 DO UPDATE SET response = EXCLUDED.response,
+					   usage = EXCLUDED.usage,
 					   group_id = EXCLUDED.group_id`,
 		jobID,
 		jsonResponse,
+		jsonUsage,
 		groupID, // nil = NULL if no group
 	)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to store Ollama result in database")
+		log.Error().Err(err).Msg("Failed to store LLM result in database")
+		return err
+	}
+	return nil
+}
+
+// recordSchemaAttempts stores every structured-output attempt of a subtask,
+// including first-try successes, so the share of calls needing repair can
+// be counted. Valid outputs are not stored again; they are in the results.
+// It writes outside the job transaction so the attempts survive a failed
+// job, and only logs on error.
+func (w *DPromptsWorker) recordSchemaAttempts(ctx context.Context, jobID int64, subtask int, attempts []SchemaAttempt) {
+	for _, a := range attempts {
+		var output, validationError *string
+		if a.ValidationError != "" {
+			output, validationError = &a.Output, &a.ValidationError
+		}
+
+		_, err := w.db.Exec(ctx,
+			`INSERT INTO dprompts_schema_attempts (job_id, subtask_index, attempt, output, validation_error)
+			 VALUES ($1, $2, $3, $4, $5)`,
+			jobID,
+			subtask,
+			a.Attempt,
+			output,
+			validationError,
+		)
+		if err != nil {
+			log.Error().Err(err).Int64("job_id", jobID).Int("subtask", subtask).Msg("Failed to record schema attempts")
+			return
+		}
+	}
+
+	if len(attempts) == 1 && attempts[0].ValidationError == "" {
+		return
+	}
+	log.Warn().
+		Int64("job_id", jobID).
+		Int("subtask", subtask).
+		Int("attempts", len(attempts)).
+		Bool("repaired", attempts[len(attempts)-1].ValidationError == "").
+		Msg("Subtask output needed schema repair")
+}
+
+// loadSubtaskResults returns the outputs and metrics of subtasks already
+// completed by previous attempts of the job, keyed like the final result
+// (subtask_N).
+func (w *DPromptsWorker) loadSubtaskResults(ctx context.Context, jobID int64) (map[string]string, map[string]SubtaskMetrics, error) {
+	rows, err := w.db.Query(ctx, `
+		SELECT subtask_index, response, usage
+		FROM dprompts_subtask_results
+		WHERE job_id = $1
+	`, jobID)
+	if err != nil {
+		log.Error().Err(err).Int64("job_id", jobID).Msg("Failed to load subtask progress")
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string]string)
+	metrics := make(map[string]SubtaskMetrics)
+	for rows.Next() {
+		var (
+			index    int
+			response string
+			usage    []byte
+		)
+		if err := rows.Scan(&index, &response, &usage); err != nil {
+			return nil, nil, err
+		}
+
+		key := fmt.Sprintf("subtask_%d", index)
+		results[key] = response
+
+		var m SubtaskMetrics
+		if len(usage) > 0 && json.Unmarshal(usage, &m) == nil {
+			metrics[key] = m
+		}
+	}
+
+	return results, metrics, rows.Err()
+}
+
+// saveSubtaskResult durably stores a finished subtask outside the job
+// transaction so a retried job can skip it.
+func (w *DPromptsWorker) saveSubtaskResult(ctx context.Context, jobID int64, subtask int, response string, m SubtaskMetrics) error {
+	usage, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.db.Exec(ctx,
+		`INSERT INTO dprompts_subtask_results (job_id, subtask_index, response, usage)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (job_id, subtask_index)
+		 DO UPDATE SET response = EXCLUDED.response,
+					   usage = EXCLUDED.usage`,
+		jobID,
+		subtask,
+		response,
+		usage,
+	)
+	if err != nil {
+		log.Error().Err(err).Int64("job_id", jobID).Int("subtask", subtask).Msg("Failed to store subtask progress")
 		return err
 	}
 	return nil
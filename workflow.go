@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// loadUpstreamResults returns the stored outputs of the jobs jobID depends
+// on, keyed by job key and then subtask_N.
+func loadUpstreamResults(ctx context.Context, db *pgxpool.Pool, jobID int64) (map[string]map[string]string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT d.depends_on_key, r.response
+		FROM dprompts_job_dependencies d
+		JOIN dprompts_results r ON r.job_id = d.depends_on_job_id
+		WHERE d.job_id = $1
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	upstream := map[string]map[string]string{}
+	for rows.Next() {
+		var (
+			key      string
+			response []byte
+		)
+		if err := rows.Scan(&key, &response); err != nil {
+			return nil, err
+		}
+		var outputs map[string]string
+		if err := json.Unmarshal(response, &outputs); err != nil {
+			return nil, fmt.Errorf("result of job %q: %w", key, err)
+		}
+		upstream[key] = outputs
+	}
+	return upstream, rows.Err()
+}
+
+// renderUpstreamResults fills {{job.KEY...}} placeholders in the base
+// prompt. Subtask prompts are rendered as each subtask runs, together with
+// the outputs of earlier subtasks, so here they are only checked, to fail
+// before any LLM call. Upstream results that no placeholder references are
+// appended to the base prompt as context.
+func renderUpstreamResults(args DPromptsJobArgs, upstream map[string]map[string]string) (DPromptsJobArgs, error) {
+	r := promptRenderer{upstream: upstream}
+
+	basePrompt, err := r.render(args.BasePrompt)
+	if err != nil {
+		return args, err
+	}
+	for _, sub := range args.SubTasks {
+		if _, err := r.render(sub.Prompt); err != nil {
+			return args, err
+		}
+	}
+
+	var extra strings.Builder
+	for _, key := range args.DependsOn {
+		if r.jobs[key] {
+			continue
+		}
+		outputs, ok := upstream[key]
+		if !ok {
+			return args, fmt.Errorf("result of job %q is not available", key)
+		}
+		whole, _ := lookupJobOutput(outputs, "")
+		fmt.Fprintf(&extra, "\n\nResult of job %s:\n%s", key, whole)
+	}
+	args.BasePrompt = strings.TrimLeft(basePrompt+extra.String(), "\n")
+
+	return args, nil
+}
+
+// lookupJobOutput resolves a path in an upstream job's result. The first
+// part may pick a subtask_N; for single-subtask jobs it can be left out.
+// An empty path returns the only output, or all outputs as JSON.
+func lookupJobOutput(outputs map[string]string, path string) (string, error) {
+	first, rest, _ := strings.Cut(path, ".")
+	if output, ok := outputs[first]; ok {
+		return lookupOutputPath(output, rest)
+	}
+
+	if len(outputs) == 1 {
+		for _, output := range outputs {
+			return lookupOutputPath(output, path)
+		}
+	}
+	if path != "" {
+		return "", fmt.Errorf("job has %d subtasks, pick one with .subtask_N", len(outputs))
+	}
+
+	all, err := json.Marshal(outputs)
+	if err != nil {
+		return "", err
+	}
+	return string(all), nil
+}
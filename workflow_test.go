@@ -0,0 +1,130 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookupJobOutput(t *testing.T) {
+	single := map[string]string{"subtask_0": `{"title":"T","tags":["a","b"]}`}
+	multi := map[string]string{"subtask_0": "plain", "subtask_1": `{"n":1}`}
+
+	tests := []struct {
+		name    string
+		outputs map[string]string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"whole single output", single, "", `{"title":"T","tags":["a","b"]}`, false},
+		{"field of single output", single, "title", "T", false},
+		{"subtask then field", single, "subtask_0.tags.1", "b", false},
+		{"array as JSON", single, "tags", `["a","b"]`, false},
+		{"missing field", single, "body", "", true},
+		{"all outputs as JSON", multi, "", `{"subtask_0":"plain","subtask_1":"{\"n\":1}"}`, false},
+		{"pick a subtask", multi, "subtask_0", "plain", false},
+		{"field of a subtask", multi, "subtask_1.n", "1", false},
+		{"ambiguous field", multi, "n", "", true},
+		{"field of plain text", multi, "subtask_0.x", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupJobOutput(tt.outputs, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupJobOutput(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("lookupJobOutput(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderUpstreamResults(t *testing.T) {
+	upstream := map[string]map[string]string{
+		"facts":   {"subtask_0": `{"topic":"tides"}`},
+		"outline": {"subtask_0": "1. Moon\n2. Sun"},
+		"quoted":  {"subtask_0": "see {{subtask_0}} and {{job.facts}}"},
+	}
+
+	tests := []struct {
+		name     string
+		args     DPromptsJobArgs
+		wantBase string
+		wantErr  string
+	}{
+		{
+			name: "base prompt filled",
+			args: DPromptsJobArgs{
+				DependsOn:  []string{"facts", "outline"},
+				BasePrompt: "Topic: {{job.facts.topic}}",
+				SubTasks:   []DPromptsSubTask{{Prompt: "Expand:\n{{ job.outline }}"}},
+			},
+			wantBase: "Topic: tides",
+		},
+		{
+			name: "unreferenced dependency appended to base prompt",
+			args: DPromptsJobArgs{
+				DependsOn: []string{"outline"},
+				SubTasks:  []DPromptsSubTask{{Prompt: "Write it"}},
+			},
+			wantBase: "Result of job outline:\n1. Moon\n2. Sun",
+		},
+		{
+			name: "results are not rendered again",
+			args: DPromptsJobArgs{
+				DependsOn:  []string{"quoted"},
+				BasePrompt: "Check: {{job.quoted}}",
+				SubTasks:   []DPromptsSubTask{{Prompt: "x"}},
+			},
+			wantBase: "Check: see {{subtask_0}} and {{job.facts}}",
+		},
+		{
+			name: "literal braces without dependencies",
+			args: DPromptsJobArgs{
+				BasePrompt: `Use {{"{{"}}name}} in templates`,
+				SubTasks:   []DPromptsSubTask{{Prompt: "x"}},
+			},
+			wantBase: "Use {{name}} in templates",
+		},
+		{
+			name: "missing result",
+			args: DPromptsJobArgs{
+				DependsOn: []string{"later"},
+				SubTasks:  []DPromptsSubTask{{Prompt: "{{job.later}}"}},
+			},
+			wantErr: `result of job "later" is not available`,
+		},
+		{
+			name: "bad path in a subtask prompt",
+			args: DPromptsJobArgs{
+				DependsOn: []string{"facts"},
+				SubTasks:  []DPromptsSubTask{{Prompt: "{{job.facts.nope}}"}},
+			},
+			wantErr: `field "nope" not found`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderUpstreamResults(tt.args, upstream)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderUpstreamResults: %v", err)
+			}
+			if got.BasePrompt != tt.wantBase {
+				t.Errorf("base prompt = %q, want %q", got.BasePrompt, tt.wantBase)
+			}
+			if !reflect.DeepEqual(got.SubTasks, tt.args.SubTasks) {
+				t.Errorf("subtask prompts changed: %+v", got.SubTasks)
+			}
+		})
+	}
+}